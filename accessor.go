@@ -0,0 +1,177 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"errors"
+	"strconv"
+	"unsafe"
+)
+
+// An Accessor reads the value at a path inside values of a struct type,
+// resolved once into a chain of offsets, see CompileAccessor.
+// An Accessor is safe for concurrent use.
+type Accessor struct {
+	Type *Type // type of the accessed value
+
+	hops   []accessHop
+	offset uintptr // offset after the last hop
+	ro     bool    // the path selects unexported fields
+}
+
+// accessHop adds offset to the address, then loads the pointer
+// or the element index of the slice found there.
+type accessHop struct {
+	offset uintptr
+	slice  bool
+	index  int
+	size   uintptr // element size of the slice
+}
+
+// CompileAccessor resolves the path in values of type t once, the path
+// has the syntax of Query with field names and indexes:
+//
+//	Header.ID
+//	Items[2].Price
+//
+// Pointers are dereferenced implicitly. Array indexes are checked here,
+// slice indexes and nil pointers when the Accessor is used.
+// Interfaces, maps and wildcards cannot be compiled into offsets.
+func CompileAccessor(t *Type, path string) (*Accessor, error) {
+	if t == nil {
+		return nil, errors.New("surface: CompileAccessor of nil type")
+	}
+	steps, err := parseQuery(path)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(reason string) (*Accessor, error) {
+		return nil, errors.New("surface: CompileAccessor " + t.String() + " " + strconv.Quote(path) + ": " + reason)
+	}
+
+	a := &Accessor{}
+	cur := t
+	for _, s := range steps {
+		for cur.Kind() == KPtr {
+			a.hops = append(a.hops, accessHop{offset: a.offset})
+			a.offset = 0
+			cur = cur.Ptr().Elem
+		}
+		switch {
+		case s.kind == stepField && cur.Kind() == KStruct:
+			found := false
+			for _, f := range cur.Struct().Fields {
+				if f.Name() == s.name {
+					a.offset += f.offset
+					a.ro = a.ro || !f.Exported()
+					cur = f.Type
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fail("no field " + s.name + " in " + cur.String())
+			}
+
+		case s.kind == stepIndex && cur.Kind() == KArray:
+			at := cur.Array()
+			if s.index < 0 || s.index >= int64(at.Len()) {
+				return fail("index " + strconv.FormatInt(s.index, 10) + " out of range")
+			}
+			a.offset += uintptr(s.index) * at.Elem.Size
+			cur = at.Elem
+
+		case s.kind == stepIndex && cur.Kind() == KSlice:
+			if s.index < 0 {
+				return fail("negative index")
+			}
+			elem := cur.Slice().Elem
+			a.hops = append(a.hops, accessHop{a.offset, true, int(s.index), elem.Size})
+			a.offset = 0
+			cur = elem
+
+		default:
+			return fail("cannot select in " + cur.String())
+		}
+	}
+	a.Type = cur
+	return a, nil
+}
+
+// at returns the address of the accessed value in the value at p,
+// nil if the path goes through a nil pointer or out of a slice.
+func (a *Accessor) at(p unsafe.Pointer) unsafe.Pointer {
+	if p == nil {
+		return nil
+	}
+	for _, h := range a.hops {
+		p = unsafe.Pointer(uintptr(p) + h.offset)
+		if h.slice {
+			s := (*SliceHeader)(p)
+			if h.index >= s.Len {
+				return nil
+			}
+			p = unsafe.Pointer(s.Data + uintptr(h.index)*h.size)
+		} else if p = *(*unsafe.Pointer)(p); p == nil {
+			return nil
+		}
+	}
+	return unsafe.Pointer(uintptr(p) + a.offset)
+}
+
+// Get returns the accessed value in the value of the compiled type at ptr.
+// The result is addressable, and read-only if the path selects unexported fields.
+// Get returns the zero Value if the path goes through a nil pointer or
+// out of the bounds of a slice.
+func (a *Accessor) Get(ptr unsafe.Pointer) Value {
+	p := a.at(ptr)
+	if p == nil {
+		return Value{}
+	}
+	fl := flagIndir | flagAddr | flag(a.Type.Kind())<<flagKindShift
+	if a.ro {
+		fl |= flagRO
+	}
+	return Value{a.Type, sur{p, 0, fl, unsafe.Pointer(a.Type)}}
+}
+
+// GetInt64 returns the accessed signed integer as an int64, 0 if the path
+// goes through a nil pointer or out of a slice.
+// It panics if the accessed type is not of a signed integer kind.
+func (a *Accessor) GetInt64(ptr unsafe.Pointer) int64 {
+	k := a.Type.Kind()
+	if !isIntKind(k) {
+		panic(&ValueError{"surface.Accessor.GetInt64", k})
+	}
+	p := a.at(ptr)
+	if p == nil {
+		return 0
+	}
+	switch k {
+	case KInt:
+		return int64(*(*int)(p))
+	case KInt8:
+		return int64(*(*int8)(p))
+	case KInt16:
+		return int64(*(*int16)(p))
+	case KInt32:
+		return int64(*(*int32)(p))
+	}
+	return *(*int64)(p)
+}
+
+// GetString returns the accessed string, "" if the path goes through
+// a nil pointer or out of a slice.
+// It panics if the accessed type is not of kind KString.
+func (a *Accessor) GetString(ptr unsafe.Pointer) string {
+	if k := a.Type.Kind(); k != KString {
+		panic(&ValueError{"surface.Accessor.GetString", k})
+	}
+	p := a.at(ptr)
+	if p == nil {
+		return ""
+	}
+	return *(*string)(p)
+}
@@ -197,3 +197,199 @@ func (v Value) Bytes() []byte {
 	v.mustBe(KString)
 	return *(*[]byte)(v.val)
 }
+
+// SetBool sets v's underlying value.
+// It panics if v's Kind is not KBool or if CanSet() is false.
+func (v Value) SetBool(x bool) {
+	v.mustBeAssignable()
+	v.mustBe(KBool)
+	*(*bool)(v.val) = x
+}
+
+func (v Value) SetInt(x int) {
+	v.mustBeAssignable()
+	v.mustBe(KInt)
+	*(*int)(v.val) = x
+}
+
+func (v Value) SetUint(x uint) {
+	v.mustBeAssignable()
+	v.mustBe(KUint)
+	*(*uint)(v.val) = x
+}
+
+func (v Value) SetInt8(x int8) {
+	v.mustBeAssignable()
+	v.mustBe(KInt8)
+	*(*int8)(v.val) = x
+}
+func (v Value) SetInt16(x int16) {
+	v.mustBeAssignable()
+	v.mustBe(KInt16)
+	*(*int16)(v.val) = x
+}
+func (v Value) SetInt32(x int32) {
+	v.mustBeAssignable()
+	v.mustBe(KInt32)
+	*(*int32)(v.val) = x
+}
+
+// SetInt64 sets v's underlying value to x, truncated to the size of v.
+// It panics if v's Kind is not KInt, KInt8, KInt16, KInt32, or KInt64,
+// or if CanSet() is false.
+func (v Value) SetInt64(x int64) {
+	v.mustBeAssignable()
+	switch k := v.Kind(); k {
+	default:
+		panic(&ValueError{"surface.Value.SetInt64", k})
+	case KInt:
+		*(*int)(v.val) = int(x)
+	case KInt8:
+		*(*int8)(v.val) = int8(x)
+	case KInt16:
+		*(*int16)(v.val) = int16(x)
+	case KInt32:
+		*(*int32)(v.val) = int32(x)
+	case KInt64:
+		*(*int64)(v.val) = x
+	}
+}
+
+// SetUint64 sets v's underlying value to x, truncated to the size of v.
+// It panics if v's Kind is not KUint, KUintptr, KUint8, KUint16, KUint32, or KUint64,
+// or if CanSet() is false.
+func (v Value) SetUint64(x uint64) {
+	v.mustBeAssignable()
+	switch k := v.Kind(); k {
+	default:
+		panic(&ValueError{"surface.Value.SetUint64", k})
+	case KUint:
+		*(*uint)(v.val) = uint(x)
+	case KUint8:
+		*(*uint8)(v.val) = uint8(x)
+	case KUint16:
+		*(*uint16)(v.val) = uint16(x)
+	case KUint32:
+		*(*uint32)(v.val) = uint32(x)
+	case KUint64:
+		*(*uint64)(v.val) = x
+	case KUintptr:
+		*(*uintptr)(v.val) = uintptr(x)
+	}
+}
+
+func (v Value) SetUint8(x uint8) {
+	v.mustBeAssignable()
+	v.mustBe(KUint8)
+	*(*uint8)(v.val) = x
+}
+func (v Value) SetUint16(x uint16) {
+	v.mustBeAssignable()
+	v.mustBe(KUint16)
+	*(*uint16)(v.val) = x
+}
+func (v Value) SetUint32(x uint32) {
+	v.mustBeAssignable()
+	v.mustBe(KUint32)
+	*(*uint32)(v.val) = x
+}
+
+func (v Value) SetUintptr(x uintptr) {
+	v.mustBeAssignable()
+	v.mustBe(KUintptr)
+	*(*uintptr)(v.val) = x
+}
+
+func (v Value) SetFloat32(x float32) {
+	v.mustBeAssignable()
+	v.mustBe(KFloat32)
+	*(*float32)(v.val) = x
+}
+
+// SetFloat64 sets v's underlying value to x.
+// It panics if v's Kind is not KFloat32 or KFloat64, or if CanSet() is false.
+func (v Value) SetFloat64(x float64) {
+	v.mustBeAssignable()
+	switch k := v.Kind(); k {
+	default:
+		panic(&ValueError{"surface.Value.SetFloat64", k})
+	case KFloat32:
+		*(*float32)(v.val) = float32(x)
+	case KFloat64:
+		*(*float64)(v.val) = x
+	}
+}
+
+func (v Value) SetComplex64(x complex64) {
+	v.mustBeAssignable()
+	v.mustBe(KComplex64)
+	*(*complex64)(v.val) = x
+}
+func (v Value) SetComplex128(x complex128) {
+	v.mustBeAssignable()
+	v.mustBe(KComplex128)
+	*(*complex128)(v.val) = x
+}
+
+func (v Value) SetString(x string) {
+	v.mustBeAssignable()
+	v.mustBe(KString)
+	*(*string)(v.val) = x
+}
+
+// SetBytes sets v's underlying value.
+// It panics if v's underlying value is not a slice of bytes or if CanSet() is false.
+func (v Value) SetBytes(x []byte) {
+	v.mustBeAssignable()
+	v.mustBe(KSlice)
+	v.Type.Slice().Elem.mustBe(KUint8)
+	*(*[]byte)(v.val) = x
+}
+
+// Set assigns x to the value v.
+// It panics if CanSet returns false, if x was obtained by accessing
+// unexported struct fields, or if x is not assignable to v's type.
+func (v Value) Set(x Value) {
+	v.mustBeAssignable()
+	x.mustBeExported() // do not let unexported x leak
+	if x.Type != v.Type {
+		if !x.Type.AssignableTo(v.Type) {
+			panic("surface: Set using " + x.Type.String() + " as type " + v.Type.String())
+		}
+		// Assignment between different types, such as storing a concrete value
+		// in an interface, needs the conversions of package reflect.
+		v.ToValue().Set(x.ToValue())
+		return
+	}
+	if x.flag&flagIndir != 0 {
+		typedmemmove(v.Type, v.val, x.val)
+	} else {
+		typedmemmove(v.Type, v.val, unsafe.Pointer(&x.val))
+	}
+}
+
+// SetZero sets v to be the zero value of v's type.
+// It panics if CanSet returns false.
+func (v Value) SetZero() {
+	v.mustBeAssignable()
+	typedmemmove(v.Type, v.val, v.Type.zero)
+}
+
+// TypedCopy copies the value of src to dst, both of the same type.
+// Unlike a raw memory copy, pointers in the value are written with
+// the write barriers of the garbage collector, so TypedCopy is safe for
+// any value while the collector runs concurrently.
+// It panics if dst is not settable, if src was obtained by accessing
+// unexported struct fields, or if the types differ.
+func TypedCopy(dst, src Value) {
+	dst.mustBeAssignable()
+	src.mustBeExported()
+	if dst.Type != src.Type {
+		panic("surface: TypedCopy of " + src.Type.String() + " to " + dst.Type.String())
+	}
+	if src.flag&flagIndir != 0 {
+		typedmemmove(dst.Type, dst.val, src.val)
+	} else {
+		typedmemmove(dst.Type, dst.val, unsafe.Pointer(&src.val))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"unsafe"
+)
+
+// Call calls the function v with the input arguments args.
+// For example, if len(args) == 3, v.Call(args) represents the Go call v(args[0], args[1], args[2]).
+// Call panics if v is nil, read-only or if an argument is not assignable
+// to the corresponding FuncType.In.
+// If v is a variadic function, Call creates the variadic slice parameter itself,
+// copying in the corresponding values.
+// Functions without results taking no argument or a single string, int, bool
+// or interface{} argument are called directly, without allocation, unless the
+// argument of a func(interface{}) is larger than a word and has to be boxed.
+func (v Func) Call(args []Value) []Value {
+	v.mustBe(KFunc)
+	v.mustBeExported()
+	v.checkCall(args, false)
+	if v.flag&flagMethod == 0 && len(v.Type.Out) == 0 && v.fastCall(args) {
+		return nil
+	}
+	return v.call(args, false)
+}
+
+// CallSlice calls the variadic function v with the input arguments args,
+// assigning the slice args[len(args)-1] to v's final variadic argument.
+// For example, if len(args) == 3, v.CallSlice(args) represents the Go call v(args[0], args[1], args[2]...).
+// CallSlice panics if v is not variadic.
+func (v Func) CallSlice(args []Value) []Value {
+	v.mustBe(KFunc)
+	v.mustBeExported()
+	v.checkCall(args, true)
+	return v.call(args, true)
+}
+
+// checkCall panics if args does not match v.Type.In.
+func (v Func) checkCall(args []Value, slice bool) {
+	if v.flag&flagMethod == 0 && v.IsNil() {
+		panic("surface: call of nil function")
+	}
+	in := v.Type.In
+	n := len(in)
+	if slice {
+		if !v.Type.DotDotDot {
+			panic("surface: CallSlice of non-variadic function")
+		}
+		if len(args) != n {
+			panic("surface: wrong argument count in CallSlice")
+		}
+	} else {
+		if v.Type.DotDotDot {
+			n--
+		}
+		if len(args) < n || !v.Type.DotDotDot && len(args) > n {
+			panic("surface: wrong argument count in Call")
+		}
+	}
+	for i, arg := range args {
+		if arg.flag == 0 {
+			panic("surface: Call using zero Value argument")
+		}
+		arg.mustBeExported()
+		var typ *Type
+		if i < n || slice {
+			typ = in[i]
+		} else {
+			typ = in[len(in)-1].Slice().Elem
+		}
+		if !arg.Type.AssignableTo(typ) {
+			panic("surface: Call using " + arg.Type.String() + " as type " + typ.String())
+		}
+	}
+}
+
+// fastCall calls v directly if its signature is one of the common ones.
+// It reports whether the call was made.
+func (v Func) fastCall(args []Value) bool {
+	var fn interface{}
+	e := (*EmptyInterface)(unsafe.Pointer(&fn))
+	e.Type = (*Type)(v.typ)
+	e.word = v.IWord()
+
+	switch fn := fn.(type) {
+	case func():
+		fn()
+	case func(string):
+		fn(args[0].String())
+	case func(int):
+		fn(args[0].Int())
+	case func(bool):
+		fn(args[0].Bool())
+	case func(interface{}):
+		arg := args[0]
+		switch {
+		case arg.Kind() == KInterface && arg.Type.NumMethod() == 0:
+			fn(*(*interface{})(arg.val))
+		case arg.flag&flagMethod == 0 && arg.Type.Size <= ptrSize:
+			// The value fits in the interface word, no boxing.
+			var i interface{}
+			e := (*EmptyInterface)(unsafe.Pointer(&i))
+			e.Type = arg.Type
+			e.word = arg.IWord()
+			fn(i)
+		default:
+			fn(arg.Interface())
+		}
+	default:
+		return false
+	}
+	return true
+}
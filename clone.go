@@ -0,0 +1,160 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+// A CloneOption changes how Clone copies channels.
+type CloneOption func(*cloneConfig)
+
+type cloneConfig struct {
+	chans int
+}
+
+const (
+	cloneChansEmpty = iota
+	cloneChansShare
+	cloneChansNil
+)
+
+// ShareChans makes the clone use the same channels as the original.
+func ShareChans() CloneOption {
+	return func(c *cloneConfig) {
+		c.chans = cloneChansShare
+	}
+}
+
+// NilChans leaves the channels of the clone nil.
+func NilChans() CloneOption {
+	return func(c *cloneConfig) {
+		c.chans = cloneChansNil
+	}
+}
+
+type cloner struct {
+	*cloneConfig
+	ptrs map[ptrKey]Value
+	maps map[uintptr]Value
+}
+
+type ptrKey struct {
+	p   uintptr
+	typ *Type
+}
+
+// Clone returns a deep copy of v, the result is addressable,
+// and read-only if v is.
+// Every value reachable from v is copied, unexported fields included.
+// Pointers and maps reached more than once are copied once, so shared and
+// cyclic structures keep their shape. Slices are copied with their length and
+// capacity, each slice gets its own backing array.
+// By default a channel is replaced by a new empty channel with the same capacity,
+// receive-only and send-only channels are shared. Strings and funcs are shared,
+// they are immutable.
+func Clone(v Value, opts ...CloneOption) Value {
+	if !v.IsValid() {
+		return Value{}
+	}
+	c := &cloner{
+		cloneConfig: &cloneConfig{},
+		ptrs:        make(map[ptrKey]Value),
+		maps:        make(map[uintptr]Value),
+	}
+	for _, opt := range opts {
+		opt(c.cloneConfig)
+	}
+	x := New(v.Type).Elem()
+	c.clone(x, v)
+	x.flag |= v.flag & flagRO
+	return x
+}
+
+// clone sets dst, a settable zero value, to a deep copy of src.
+func (c *cloner) clone(dst, src Value) {
+	dst, src = Unrestricted(dst), Unrestricted(src)
+	switch src.Kind() {
+	case KPtr:
+		if src.IsNil() {
+			return
+		}
+		key := ptrKey{src.Pointer(), src.Type}
+		if x, ok := c.ptrs[key]; ok {
+			dst.Set(x)
+			return
+		}
+		p := New(src.Type.Ptr().Elem)
+		x := Value{&p.Type.Type, p.sur}
+		c.ptrs[key] = x
+		c.clone(p.Elem(), src.Ptr().Elem())
+		dst.Set(x)
+
+	case KInterface:
+		e := src.Surface().Elem()
+		if !e.IsValid() {
+			return
+		}
+		x := New(e.Type).Elem()
+		c.clone(x, e)
+		dst.Set(x)
+
+	case KStruct:
+		d, s := dst.Struct(), src.Struct()
+		for i := range s.Type.Fields {
+			c.clone(d.Field(i), s.Field(i))
+		}
+
+	case KArray:
+		d, s := dst.Array(), src.Array()
+		for i := 0; i < s.Len(); i++ {
+			c.clone(d.Index(i), s.Index(i))
+		}
+
+	case KSlice:
+		if src.IsNil() {
+			return
+		}
+		s := src.Slice()
+		n := MakeSlice(src.Type, s.Len(), s.Cap())
+		for i := 0; i < s.Len(); i++ {
+			c.clone(n.Index(i), s.Index(i))
+		}
+		dst.Set(Value{&n.Type.Type, n.sur})
+
+	case KMap:
+		if src.IsNil() {
+			return
+		}
+		if x, ok := c.maps[src.Pointer()]; ok {
+			dst.Set(x)
+			return
+		}
+		s := src.Map()
+		m := MakeMapWithSize(src.Type, s.Len())
+		x := Value{&m.Type.Type, m.sur}
+		c.maps[src.Pointer()] = x
+		keys, elems := s.entries()
+		for i, key := range keys {
+			k := New(s.Type.Key).Elem()
+			c.clone(k, key)
+			e := New(s.Type.Elem).Elem()
+			c.clone(e, elems[i])
+			m.SetIndex(k, e)
+		}
+		dst.Set(x)
+
+	case KChan:
+		if src.IsNil() || c.chans == cloneChansNil {
+			return
+		}
+		ch := src.Chan()
+		if c.chans == cloneChansShare || ch.Type.Direction() != BothDir {
+			dst.Set(src)
+			return
+		}
+		n := MakeChan(src.Type, ch.Cap())
+		dst.Set(Value{&n.Type.Type, n.sur})
+
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Convert returns the value v converted to type t,
+// following the conversion rules of the Go language:
+//
+//	numeric types to each other, truncating or rounding as Go does
+//	integers to string, as a code point
+//	string to and from []byte and []rune
+//	types with identical underlying types to each other, e.g. named to underlying
+//	values to the interface types they implement
+//	slice to pointer to array of the same element type
+//
+// The returned value is not addressable, it is read-only if v is.
+// Convert returns an error if the conversion is not allowed,
+// or if a slice is shorter than the array it is converted to.
+func (v Value) Convert(t *Type) (Value, error) {
+	if v.flag == 0 {
+		panic(&ValueError{"surface.Value.Convert", KInvalid})
+	}
+	if t == nil || v.flag&flagMethod != 0 {
+		return Value{}, v.convertError(t)
+	}
+	x, err := v.convert(t)
+	if err != nil {
+		return Value{}, err
+	}
+	x.flag = x.flag&^flagAddr | v.flag&flagRO
+	return x, nil
+}
+
+func (v Value) convertError(t *Type) error {
+	return errors.New("surface: cannot convert " + v.Type.String() + " to " + t.String())
+}
+
+func (v Value) convert(t *Type) (Value, error) {
+	sk, dk := v.Kind(), t.Kind()
+	x := New(t).Elem()
+	switch {
+	case isNumberKind(sk) && isNumberKind(dk):
+		convertNumber(x, v)
+		return x, nil
+
+	case isComplexKind(sk) && isComplexKind(dk):
+		var c complex128
+		if sk == KComplex64 {
+			c = complex128(v.Complex64())
+		} else {
+			c = v.Complex128()
+		}
+		if dk == KComplex64 {
+			x.SetComplex64(complex64(c))
+		} else {
+			x.SetComplex128(c)
+		}
+		return x, nil
+
+	case dk == KString && (isIntKind(sk) || isUintKind(sk)):
+		r := utf8.RuneError
+		if isIntKind(sk) {
+			if i := v.Int64(); i >= 0 && i <= utf8.MaxRune {
+				r = rune(i)
+			}
+		} else if u := v.Uint64(); u <= utf8.MaxRune {
+			r = rune(u)
+		}
+		x.SetString(string(r))
+		return x, nil
+
+	case sk == KString && dk == KSlice:
+		switch t.Slice().Elem.Kind() {
+		case KUint8:
+			*(*[]byte)(x.val) = []byte(v.String())
+			return x, nil
+		case KInt32:
+			*(*[]rune)(x.val) = []rune(v.String())
+			return x, nil
+		}
+
+	case sk == KSlice && dk == KString:
+		switch v.Type.Slice().Elem.Kind() {
+		case KUint8:
+			x.SetString(string(v.Slice().Bytes()))
+			return x, nil
+		case KInt32:
+			x.SetString(string(v.Slice().Runes()))
+			return x, nil
+		}
+
+	case sk == KSlice && dk == KPtr && t.Ptr().Elem.Kind() == KArray:
+		at := t.Ptr().Elem.Array()
+		if at.Elem != v.Type.Slice().Elem {
+			break
+		}
+		s := v.Slice()
+		if s.Len() < at.Len() {
+			return Value{}, errors.New("surface: cannot convert slice with length " +
+				strconv.Itoa(s.Len()) + " to " + t.String())
+		}
+		*(*unsafe.Pointer)(x.val) = s.DataPointer()
+		return x, nil
+
+	case dk == KInterface:
+		if v.Type.Implements(t.Surface()) {
+			x.Set(Unrestricted(v))
+			return x, nil
+		}
+
+	case sk == dk && toType(v.Type).ConvertibleTo(toType(t)):
+		// Identical underlying types, the memory is reused as is.
+		if v.flag&flagIndir != 0 {
+			typedmemmove(t, x.val, v.val)
+		} else {
+			typedmemmove(t, x.val, unsafe.Pointer(&v.val))
+		}
+		return x, nil
+	}
+	return Value{}, v.convertError(t)
+}
+
+// convertNumber stores the integer or float value v in x.
+func convertNumber(x, v Value) {
+	sk, dk := v.Kind(), x.Kind()
+	switch {
+	case isIntKind(dk):
+		switch {
+		case isIntKind(sk):
+			x.SetInt64(v.Int64())
+		case isUintKind(sk):
+			x.SetInt64(int64(v.Uint64()))
+		default:
+			x.SetInt64(int64(v.Float64()))
+		}
+	case isUintKind(dk):
+		switch {
+		case isIntKind(sk):
+			x.SetUint64(uint64(v.Int64()))
+		case isUintKind(sk):
+			x.SetUint64(v.Uint64())
+		default:
+			x.SetUint64(uint64(v.Float64()))
+		}
+	default:
+		switch {
+		case isIntKind(sk):
+			x.SetFloat64(float64(v.Int64()))
+		case isUintKind(sk):
+			x.SetFloat64(float64(v.Uint64()))
+		default:
+			x.SetFloat64(v.Float64())
+		}
+	}
+}
+
+func isIntKind(k Kind) bool {
+	return k >= KInt && k <= KInt64
+}
+
+func isUintKind(k Kind) bool {
+	return k >= KUint && k <= KUintptr
+}
+
+func isFloatKind(k Kind) bool {
+	return k == KFloat32 || k == KFloat64
+}
+
+func isComplexKind(k Kind) bool {
+	return k == KComplex64 || k == KComplex128
+}
+
+func isNumberKind(k Kind) bool {
+	return isIntKind(k) || isUintKind(k) || isFloatKind(k)
+}
@@ -0,0 +1,254 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"math"
+)
+
+// An EqualOption changes the rules of DeepEqual.
+type EqualOption func(*equalConfig)
+
+type equalConfig struct {
+	tolerance float64
+	nilEmpty  bool
+	ignore    []string
+	comparers map[*Type]func(a, b Value) bool
+}
+
+// FloatTolerance makes floats, and the parts of complex numbers,
+// equal when they differ by no more than eps.
+func FloatTolerance(eps float64) EqualOption {
+	return func(c *equalConfig) {
+		c.tolerance = eps
+	}
+}
+
+// NilEqualsEmpty makes a nil slice or map equal to an empty one.
+func NilEqualsEmpty() EqualOption {
+	return func(c *equalConfig) {
+		c.nilEmpty = true
+	}
+}
+
+// IgnoreFields skips the values at the given paths, such as
+// "Profile.UpdatedAt" or "Users[*].Password". The index "[*]"
+// matches any slice or array index and any map key.
+func IgnoreFields(paths ...string) EqualOption {
+	return func(c *equalConfig) {
+		c.ignore = append(c.ignore, paths...)
+	}
+}
+
+// Comparer compares values of type t with fn instead of the default rules.
+func Comparer(t *Type, fn func(a, b Value) bool) EqualOption {
+	return func(c *equalConfig) {
+		if c.comparers == nil {
+			c.comparers = make(map[*Type]func(a, b Value) bool)
+		}
+		c.comparers[t] = fn
+	}
+}
+
+// visit records a comparison in progress, for cycle detection.
+type visit struct {
+	a, b uintptr
+	typ  *Type
+	len  int
+}
+
+type deepEqual struct {
+	*equalConfig
+	visited map[visit]bool
+}
+
+// DeepEqual reports whether a and b are deeply equal.
+// The rules are those of reflect.DeepEqual, changed by opts:
+// values of different types are never equal, unexported struct fields
+// are compared too, and pointers, maps and slices are followed with cycle detection.
+// Funcs are equal only if both are nil.
+func DeepEqual(a, b Value, opts ...EqualOption) bool {
+	c := &equalConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	e := &deepEqual{c, make(map[visit]bool)}
+	return e.equal(a, b, "")
+}
+
+// field, index and key return the path of a child,
+// paths are only tracked to ignore fields.
+func (e *deepEqual) field(path, name string) string {
+	if e.ignore == nil {
+		return ""
+	}
+	return joinField(path, name)
+}
+
+func (e *deepEqual) index(path string, i int) string {
+	if e.ignore == nil {
+		return ""
+	}
+	return joinIndex(path, i)
+}
+
+func (e *deepEqual) key(path string, key Value) string {
+	if e.ignore == nil {
+		return ""
+	}
+	return joinKey(path, key)
+}
+
+func (e *deepEqual) ignored(path string) bool {
+	for _, pattern := range e.ignore {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *deepEqual) floatEqual(x, y float64) bool {
+	return x == y || e.tolerance != 0 && math.Abs(x-y) <= e.tolerance
+}
+
+func (e *deepEqual) equal(a, b Value, path string) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type != b.Type {
+		return false
+	}
+	if e.ignore != nil && path != "" && e.ignored(path) {
+		return true
+	}
+	if fn, ok := e.comparers[a.Type]; ok {
+		return fn(a, b)
+	}
+
+	k := a.Kind()
+	switch k {
+	case KMap, KSlice, KPtr:
+		pa, pb := a.Pointer(), b.Pointer()
+		if pa != 0 && pb != 0 {
+			if pa > pb {
+				// Canonicalize order to reduce number of entries in visited.
+				pa, pb = pb, pa
+			}
+			v := visit{pa, pb, a.Type, 0}
+			if k == KSlice {
+				v.len = a.Slice().Len()
+			}
+			if e.visited[v] {
+				return true
+			}
+			e.visited[v] = true
+			if e.ignore != nil {
+				// What is ignored depends on the path, a pair equal under
+				// one path may differ under another. Only the comparisons
+				// in progress are assumed equal, to stop at cycles.
+				defer delete(e.visited, v)
+			}
+		}
+	}
+
+	switch k {
+	case KArray:
+		x, y := a.Array(), b.Array()
+		for i := 0; i < x.Len(); i++ {
+			if !e.equal(x.Index(i), y.Index(i), e.index(path, i)) {
+				return false
+			}
+		}
+		return true
+
+	case KSlice:
+		x, y := a.Slice(), b.Slice()
+		if x.IsNil() != y.IsNil() && !(e.nilEmpty && x.Len() == 0 && y.Len() == 0) {
+			return false
+		}
+		if x.Len() != y.Len() {
+			return false
+		}
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !e.equal(x.Index(i), y.Index(i), e.index(path, i)) {
+				return false
+			}
+		}
+		return true
+
+	case KInterface:
+		return e.equal(a.Surface().Elem(), b.Surface().Elem(), path)
+
+	case KPtr:
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		return e.equal(a.Ptr().Elem(), b.Ptr().Elem(), path)
+
+	case KStruct:
+		x, y := a.Struct(), b.Struct()
+		for i, f := range x.Type.Fields {
+			if !e.equal(x.Field(i), y.Field(i), e.field(path, f.Name())) {
+				return false
+			}
+		}
+		return true
+
+	case KMap:
+		x, y := a.Map(), b.Map()
+		if x.IsNil() != y.IsNil() && !(e.nilEmpty && x.Len() == 0 && y.Len() == 0) {
+			return false
+		}
+		if x.Len() != y.Len() {
+			return false
+		}
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		for _, key := range x.Keys() {
+			yv := y.Index(key)
+			if !yv.IsValid() || !e.equal(x.Index(key), yv, e.key(path, key)) {
+				return false
+			}
+		}
+		return true
+
+	case KFunc:
+		return a.IsNil() && b.IsNil()
+
+	case KChan, KUnsafePointer:
+		return a.Pointer() == b.Pointer()
+
+	case KBool:
+		return a.Bool() == b.Bool()
+
+	case KString:
+		return a.String() == b.String()
+
+	case KFloat32, KFloat64:
+		return e.floatEqual(a.Float64(), b.Float64())
+
+	case KComplex64:
+		x, y := a.Complex64(), b.Complex64()
+		return e.floatEqual(float64(real(x)), float64(real(y))) &&
+			e.floatEqual(float64(imag(x)), float64(imag(y)))
+
+	case KComplex128:
+		x, y := a.Complex128(), b.Complex128()
+		return e.floatEqual(real(x), real(y)) && e.floatEqual(imag(x), imag(y))
+	}
+
+	switch {
+	case isIntKind(k):
+		return a.Int64() == b.Int64()
+	case isUintKind(k):
+		return a.Uint64() == b.Uint64()
+	}
+	panic(&ValueError{"surface.DeepEqual", k})
+}
@@ -0,0 +1,191 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"strconv"
+)
+
+// ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	Modified    ChangeKind = iota // the value differs
+	Added                         // the value exists only in the new value
+	Removed                       // the value exists only in the old value
+	TypeChanged                   // the dynamic type differs
+)
+
+var changeKindNames = [...]string{
+	Modified:    "modified",
+	Added:       "added",
+	Removed:     "removed",
+	TypeChanged: "type-changed",
+}
+
+func (k ChangeKind) String() string {
+	if k >= 0 && int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A Change is a difference between two values found by Diff.
+type Change struct {
+	Path string     // location of the difference, e.g. Users[3].Profile.Name
+	Kind ChangeKind // kind of the difference
+	Old  Value      // the old value, the zero Value if Added
+	New  Value      // the new value, the zero Value if Removed
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "(root)"
+	}
+	switch c.Kind {
+	case Added:
+		return path + ": added " + formatKey(c.New)
+	case Removed:
+		return path + ": removed " + formatKey(c.Old)
+	case TypeChanged:
+		return path + ": type changed from " + c.Old.Type.String() + " to " + c.New.Type.String()
+	}
+	return path + ": modified " + formatKey(c.Old) + " -> " + formatKey(c.New)
+}
+
+type differ struct {
+	changes []Change
+	visited map[visit]bool
+}
+
+// Diff reports the differences between a and b, the old and the new value.
+// It walks struct fields, including unexported ones, slice and array elements,
+// map entries in key order, and follows pointers and interfaces with cycle detection.
+// Values that are not walked into are compared with DeepEqual.
+func Diff(a, b Value) []Change {
+	d := &differ{visited: make(map[visit]bool)}
+	d.diff(a, b, "")
+	return d.changes
+}
+
+func (d *differ) add(path string, kind ChangeKind, a, b Value) {
+	d.changes = append(d.changes, Change{path, kind, a, b})
+}
+
+// seen reports whether the pointers, slices or maps a and b were compared
+// before, a cycle, and records them otherwise.
+func (d *differ) seen(a, b Value) bool {
+	v := visit{a.Pointer(), b.Pointer(), a.Type, 0}
+	if a.Kind() == KSlice {
+		v.len = a.Slice().Len()
+	}
+	if d.visited[v] {
+		return true
+	}
+	d.visited[v] = true
+	return false
+}
+
+func (d *differ) diff(a, b Value, path string) {
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid():
+		d.add(path, Added, a, b)
+		return
+	case !b.IsValid():
+		d.add(path, Removed, a, b)
+		return
+	case a.Type != b.Type:
+		d.add(path, TypeChanged, a, b)
+		return
+	}
+
+	switch a.Kind() {
+	case KPtr:
+		if a.Pointer() == b.Pointer() {
+			return
+		}
+		if a.IsNil() || b.IsNil() {
+			d.add(path, Modified, a, b)
+			return
+		}
+		if d.seen(a, b) {
+			return
+		}
+		d.diff(a.Ptr().Elem(), b.Ptr().Elem(), path)
+
+	case KInterface:
+		x, y := a.Surface().Elem(), b.Surface().Elem()
+		if x.IsValid() != y.IsValid() {
+			d.add(path, Modified, a, b)
+			return
+		}
+		d.diff(x, y, path)
+
+	case KStruct:
+		x, y := a.Struct(), b.Struct()
+		for i, f := range x.Type.Fields {
+			d.diff(x.Field(i), y.Field(i), joinField(path, f.Name()))
+		}
+
+	case KArray:
+		x, y := a.Array(), b.Array()
+		for i := 0; i < x.Len(); i++ {
+			d.diff(x.Index(i), y.Index(i), joinIndex(path, i))
+		}
+
+	case KSlice:
+		x, y := a.Slice(), b.Slice()
+		if x.Len() == 0 && y.Len() == 0 {
+			if x.IsNil() != y.IsNil() {
+				d.add(path, Modified, a, b)
+			}
+			return
+		}
+		if d.seen(a, b) {
+			return
+		}
+		n := x.Len()
+		if y.Len() < n {
+			n = y.Len()
+		}
+		for i := 0; i < n; i++ {
+			d.diff(x.Index(i), y.Index(i), joinIndex(path, i))
+		}
+		for i := n; i < x.Len(); i++ {
+			d.add(joinIndex(path, i), Removed, x.Index(i), Value{})
+		}
+		for i := n; i < y.Len(); i++ {
+			d.add(joinIndex(path, i), Added, Value{}, y.Index(i))
+		}
+
+	case KMap:
+		x, y := a.Map(), b.Map()
+		if x.Len() == 0 && y.Len() == 0 {
+			if x.IsNil() != y.IsNil() {
+				d.add(path, Modified, a, b)
+			}
+			return
+		}
+		if a.Pointer() == b.Pointer() || d.seen(a, b) {
+			return
+		}
+		for _, key := range sortKeys(x.Keys()) {
+			d.diff(x.Index(key), y.Index(key), joinKey(path, key))
+		}
+		for _, key := range sortKeys(y.Keys()) {
+			if !x.Index(key).IsValid() {
+				d.add(joinKey(path, key), Added, Value{}, y.Index(key))
+			}
+		}
+
+	default:
+		if !DeepEqual(a, b) {
+			d.add(path, Modified, a, b)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DumpOptions controls the output of Dump.
+type DumpOptions struct {
+	// MaxDepth limits the depth of the dumped values, the root has depth 0.
+	// Zero means no limit.
+	MaxDepth int
+
+	// MaxWidth limits the number of elements of arrays, slices and maps,
+	// the bytes of byte slices and arrays and the bytes of strings.
+	// Zero means no limit.
+	MaxWidth int
+
+	// Indent is the indentation of one level, two spaces if empty.
+	Indent string
+}
+
+// Dump writes a human readable representation of v to w: the types,
+// unexported fields, pointer addresses, channel buffer states and the
+// contents of maps sorted by key. Byte slices and arrays are hex dumped.
+// A pointer or map already dumped is marked <already shown>,
+// which also breaks cycles. For example:
+//
+//	(*main.node) 0xc000010030 {
+//	  Name: (string) "a"
+//	  Next: (*main.node) 0xc000010030 <already shown>
+//	  Ch: (chan int) (len=0 cap=2)
+//	}
+func Dump(w io.Writer, v Value, opts DumpOptions) error {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	d := &dumper{w: w, opts: opts}
+	if !v.IsValid() {
+		d.print("<invalid Value>\n")
+		return d.err
+	}
+	err := Walker{MaxDepth: opts.MaxDepth}.Walk(v, d)
+	if err == nil {
+		err = d.err
+	}
+	return err
+}
+
+type dumper struct {
+	w      io.Writer
+	err    error
+	opts   DumpOptions
+	level  int
+	frames []dumpFrame
+}
+
+type dumpFrame struct {
+	count  int  // children entered
+	hidden bool // beyond MaxWidth, not printed
+	open   bool // a brace was printed
+}
+
+func (d *dumper) print(s ...string) {
+	for _, s := range s {
+		if d.err == nil {
+			_, d.err = io.WriteString(d.w, s)
+		}
+	}
+}
+
+func (d *dumper) indent() {
+	d.print(strings.Repeat(d.opts.Indent, d.level))
+}
+
+// inline reports whether n is printed on the line of its parent.
+func inline(n *Node) bool {
+	if n.Parent == nil {
+		return false
+	}
+	k := n.Parent.Value.Kind()
+	return k == KPtr || k == KInterface
+}
+
+func isCollection(k Kind) bool {
+	return k == KArray || k == KSlice || k == KMap
+}
+
+func (d *dumper) Enter(n *Node) error {
+	if n.Parent != nil {
+		parent := &d.frames[len(d.frames)-1]
+		parent.count++
+		if d.opts.MaxWidth > 0 && isCollection(n.Parent.Value.Kind()) && parent.count > d.opts.MaxWidth {
+			d.frames = append(d.frames, dumpFrame{hidden: true})
+			return SkipChildren
+		}
+	}
+	d.frames = append(d.frames, dumpFrame{})
+	frame := &d.frames[len(d.frames)-1]
+
+	v := n.Value
+	if !inline(n) {
+		d.indent()
+		if n.Parent != nil && n.Parent.Value.Kind() == KStruct {
+			d.print(n.Name, ": ")
+		} else if n.Key.IsValid() {
+			d.print(formatKey(n.Key), ": ")
+		}
+	}
+	if !inline(n) || n.Parent.Value.Kind() == KInterface {
+		d.print("(", v.Type.String(), ") ")
+	}
+
+	k := v.Kind()
+	switch k {
+	case KPtr, KMap, KSlice, KChan, KFunc, KInterface, KUnsafePointer:
+		if v.IsNil() {
+			d.print("nil")
+			return SkipChildren
+		}
+	}
+
+	switch k {
+	case KPtr:
+		d.print("0x", strconv.FormatUint(uint64(v.Pointer()), 16))
+		switch {
+		case n.Revisit:
+			d.print(" <already shown>")
+		case d.atMaxDepth(n):
+			d.print(" ...")
+		default:
+			d.print(" ")
+		}
+		return nil
+
+	case KInterface:
+		if d.atMaxDepth(n) {
+			d.print("...")
+		}
+		return nil
+
+	case KChan:
+		c := v.Chan()
+		d.print("(len=", strconv.Itoa(c.Len()), " cap=", strconv.Itoa(c.Cap()))
+		if c.Closed() {
+			d.print(" closed")
+		}
+		d.print(")")
+		return nil
+
+	case KFunc, KUnsafePointer:
+		d.print("0x", strconv.FormatUint(uint64(v.Pointer()), 16))
+		return nil
+
+	case KString:
+		s := v.String()
+		d.print("(len=", strconv.Itoa(len(s)), ") ")
+		if d.opts.MaxWidth > 0 && len(s) > d.opts.MaxWidth {
+			d.print(strconv.Quote(s[:d.opts.MaxWidth]), "...")
+		} else {
+			d.print(strconv.Quote(s))
+		}
+		return nil
+
+	case KStruct:
+		if v.Struct().Type.NumField() == 0 {
+			d.print("{}")
+			return SkipChildren
+		}
+		return d.open(n, frame, "")
+
+	case KMap:
+		if n.Revisit {
+			d.print("0x", strconv.FormatUint(uint64(v.Pointer()), 16), " <already shown>")
+			return SkipChildren
+		}
+		return d.open(n, frame, "(len="+strconv.Itoa(v.Map().Len())+") ")
+
+	case KSlice:
+		s := v.Slice()
+		head := "(len=" + strconv.Itoa(s.Len()) + " cap=" + strconv.Itoa(s.Cap()) + ") "
+		if s.Type.Elem.Kind() == KUint8 {
+			return d.hexdump(n, frame, head, s.Bytes())
+		}
+		return d.open(n, frame, head)
+
+	case KArray:
+		a := v.Array()
+		head := "(len=" + strconv.Itoa(a.Len()) + ") "
+		if a.Type.Elem.Kind() == KUint8 && v.flag&flagIndir != 0 {
+			return d.hexdump(n, frame, head, (*[1 << 30]byte)(v.val)[:a.Len():a.Len()])
+		}
+		return d.open(n, frame, head)
+	}
+
+	d.print(formatKey(v))
+	return nil
+}
+
+// atMaxDepth reports whether the children of n are not walked.
+func (d *dumper) atMaxDepth(n *Node) bool {
+	return d.opts.MaxDepth > 0 && n.Depth >= d.opts.MaxDepth
+}
+
+// open prints the head and the opening brace of a composite value.
+func (d *dumper) open(n *Node, frame *dumpFrame, head string) error {
+	d.print(head)
+	if d.atMaxDepth(n) {
+		d.print("{...}")
+		return SkipChildren
+	}
+	d.print("{\n")
+	d.level++
+	frame.open = true
+	return d.err
+}
+
+func (d *dumper) hexdump(n *Node, frame *dumpFrame, head string, b []byte) error {
+	more := 0
+	if d.opts.MaxWidth > 0 && len(b) > d.opts.MaxWidth {
+		more = len(b) - d.opts.MaxWidth
+		b = b[:d.opts.MaxWidth]
+	}
+	if err := d.open(n, frame, head); err != nil {
+		return err
+	}
+	for _, line := range strings.SplitAfter(hex.Dump(b), "\n") {
+		if line != "" {
+			d.indent()
+			d.print(line)
+		}
+	}
+	if more != 0 {
+		d.indent()
+		d.print("... (", strconv.Itoa(more), " more)\n")
+	}
+	return SkipChildren
+}
+
+func (d *dumper) Leave(n *Node) error {
+	frame := d.frames[len(d.frames)-1]
+	d.frames = d.frames[:len(d.frames)-1]
+	if frame.hidden {
+		return nil
+	}
+	if frame.open {
+		if more := frame.count - d.opts.MaxWidth; d.opts.MaxWidth > 0 && more > 0 {
+			d.indent()
+			d.print("... (", strconv.Itoa(more), " more)\n")
+		}
+		d.level--
+		d.indent()
+		d.print("}")
+	}
+	if !inline(n) {
+		d.print("\n")
+	}
+	return d.err
+}
@@ -0,0 +1,170 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"sort"
+)
+
+// A Report is the memory footprint of a value graph computed by Footprint.
+type Report struct {
+	Total  uintptr         // retained bytes
+	ByType []TypeFootprint // bytes per type, largest first
+}
+
+// TypeFootprint is the share of a type in a Report.
+type TypeFootprint struct {
+	Type  *Type
+	Count int     // number of memory blocks
+	Bytes uintptr // total bytes of the blocks
+}
+
+// Footprint computes the bytes retained by the value graph of v:
+//
+//	the value itself, Type.Size of v.Type
+//	pointees, Type.Size of the element type
+//	backing arrays of slices, Cap times the element size, counted for the slice type
+//	string data, counted for the string type
+//	maps, the header and the bucket arrays, counted for the map type
+//	channels, the header and the buffer, counted for the channel type
+//	values boxed in interfaces, counted for the dynamic type
+//
+// Memory reachable several times is counted once: the byte ranges of the
+// value, pointees, slices, strings and boxed values are merged, the bytes
+// shared by subslices or behind interior pointers such as &s.Field belong
+// to the range starting first, the largest one on ties. Overflow buckets of
+// maps, closures and allocator rounding are not included, the result is
+// a lower bound.
+func Footprint(v Value) Report {
+	if !v.IsValid() {
+		return Report{}
+	}
+	f := &footprint{
+		byType: make(map[*Type]*TypeFootprint),
+	}
+	if v.flag&flagAddr != 0 {
+		f.span(uintptr(v.val), v.Type.Size, v.Type)
+	} else {
+		// A copy, nothing else can point into it.
+		f.add(v.Type, v.Type.Size)
+	}
+	Walk(v, VisitFunc(f.visit))
+	f.merge()
+
+	r := Report{ByType: make([]TypeFootprint, 0, len(f.byType))}
+	for _, tf := range f.byType {
+		r.Total += tf.Bytes
+		r.ByType = append(r.ByType, *tf)
+	}
+	sort.Sort(byBytes(r.ByType))
+	return r
+}
+
+type footprint struct {
+	spans  []byteSpan
+	byType map[*Type]*TypeFootprint
+}
+
+// A byteSpan is a range of memory reached through a value of type typ.
+type byteSpan struct {
+	lo, hi uintptr
+	typ    *Type
+}
+
+func (f *footprint) add(t *Type, n uintptr) {
+	if n == 0 {
+		return
+	}
+	tf := f.byType[t]
+	if tf == nil {
+		tf = &TypeFootprint{Type: t}
+		f.byType[t] = tf
+	}
+	tf.Count++
+	tf.Bytes += n
+}
+
+// span records n bytes at p, counted by merge.
+func (f *footprint) span(p, n uintptr, t *Type) {
+	if p != 0 && n != 0 {
+		f.spans = append(f.spans, byteSpan{p, p + n, t})
+	}
+}
+
+// merge adds the bytes of the spans, each byte once.
+func (f *footprint) merge() {
+	sort.Sort(byStart(f.spans))
+	var covered uintptr
+	for _, s := range f.spans {
+		lo := s.lo
+		if lo < covered {
+			lo = covered
+		}
+		if s.hi > lo {
+			f.add(s.typ, s.hi-lo)
+			covered = s.hi
+		}
+	}
+}
+
+func (f *footprint) visit(n *Node) error {
+	v := n.Value
+	switch v.Kind() {
+	case KPtr:
+		if !v.IsNil() && !n.Revisit {
+			elem := v.Type.Ptr().Elem
+			f.span(v.Pointer(), elem.Size, elem)
+		}
+
+	case KSlice:
+		s := v.Slice()
+		lo, hi := s.span(s.Cap())
+		f.span(lo, hi-lo, v.Type)
+
+	case KString:
+		h := v.StringHeader()
+		f.span(h.Data, uintptr(h.Len), v.Type)
+
+	case KMap:
+		if !v.IsNil() && !n.Revisit {
+			f.add(v.Type, mapSize(v.Type.Map(), v.IWord()))
+		}
+
+	case KChan:
+		if !v.IsNil() {
+			f.span(v.Pointer(), chanSize(v.IWord()), v.Type)
+		}
+
+	case KInterface:
+		e := v.Surface()
+		if e.TargetType != nil && e.flag&flagIndir != 0 {
+			f.span(uintptr(e.val), e.TargetType.Size, e.TargetType)
+		}
+	}
+	return nil
+}
+
+// byStart sorts spans by start address, the largest first on ties.
+type byStart []byteSpan
+
+func (s byStart) Len() int      { return len(s) }
+func (s byStart) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byStart) Less(i, j int) bool {
+	if s[i].lo != s[j].lo {
+		return s[i].lo < s[j].lo
+	}
+	return s[i].hi > s[j].hi
+}
+
+type byBytes []TypeFootprint
+
+func (s byBytes) Len() int      { return len(s) }
+func (s byBytes) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byBytes) Less(i, j int) bool {
+	if s[i].Bytes != s[j].Bytes {
+		return s[i].Bytes > s[j].Bytes
+	}
+	return s[i].Type.String() < s[j].Type.String()
+}
@@ -0,0 +1,367 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"errors"
+	"go/format"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GoLiteral returns Go source code building a copy of v, such as
+//
+//	ast.Ident{
+//		NamePos: 12,
+//		Name:    "x",
+//	}
+//
+// Named types are qualified with the name of their package, except those of
+// the package given by InPackage. The caller has to import these packages,
+// and math for NaN and infinities. Zero struct fields are omitted, unexported
+// fields are emitted, so the literal only compiles inside the package
+// declaring them.
+// Pointers to non-composite values, and pointers and maps reached more than
+// once, are built in helper variables of a function literal, which keeps
+// shared and cyclic structures intact:
+//
+//	func() *main.node {
+//		p1 := new(main.node)
+//		*p1 = main.node{
+//			Next: p1,
+//		}
+//		return p1
+//	}()
+//
+// Non-nil funcs and unsafe pointers, maps with NaN keys and types declared
+// inside functions cannot be written in Go, GoLiteral returns an error
+// for them. A non-nil channel becomes a new empty channel of the same capacity.
+func GoLiteral(v Value, opts ...LiteralOption) (string, error) {
+	if !v.IsValid() {
+		return "nil", nil
+	}
+	g := &golit{
+		literalConfig: &literalConfig{},
+		refs:          make(map[ptrKey]int),
+		names:         make(map[ptrKey]string),
+	}
+	for _, opt := range opts {
+		opt(g.literalConfig)
+	}
+	Walk(v, VisitFunc(func(n *Node) error {
+		if k := n.Value.Kind(); (k == KPtr || k == KMap) && !n.Value.IsNil() {
+			g.refs[ptrKey{n.Value.Pointer(), n.Type}]++
+		}
+		return nil
+	}))
+
+	lit, err := g.expr(v, false)
+	if err == nil {
+		err = g.err
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(g.decls) != 0 {
+		lines := append(g.decls, g.assigns...)
+		lit = "func() " + g.typeName(v.Type) + " {\n" +
+			strings.Join(lines, "\n") + "\n" +
+			"return " + lit + "\n}()"
+	}
+	// Format an assignment, a leading func literal would parse as a declaration.
+	src, err := format.Source([]byte("_ = " + lit))
+	if err != nil {
+		return "", errors.New("surface: GoLiteral generated invalid code: " + err.Error())
+	}
+	return strings.TrimPrefix(string(src), "_ = "), nil
+}
+
+// A LiteralOption changes the code generated by GoLiteral.
+type LiteralOption func(*literalConfig)
+
+type literalConfig struct {
+	pkg string
+}
+
+// InPackage generates code for the package with the import path pkg,
+// its types are not qualified.
+func InPackage(pkg string) LiteralOption {
+	return func(c *literalConfig) {
+		c.pkg = pkg
+	}
+}
+
+type golit struct {
+	*literalConfig
+	refs    map[ptrKey]int    // references to pointees and maps
+	names   map[ptrKey]string // helper variables
+	decls   []string
+	assigns []string
+	vars    int
+	err     error // first type that cannot be named
+}
+
+// typeName returns the Go syntax of t, named types are qualified
+// with the name of their package.
+func (g *golit) typeName(t *Type) string {
+	if name := t.Name(); name != "" {
+		if strings.ContainsRune(name, '·') && g.err == nil {
+			// The compiler numbers the names of types declared in functions.
+			g.err = errors.New("surface: GoLiteral of " + t.String() + " declared in a function")
+		}
+		if pkg := t.PkgPath(); pkg == "" || pkg == g.pkg {
+			return name
+		}
+		return t.String()
+	}
+	switch t.Kind() {
+	case KArray:
+		return "[" + strconv.Itoa(t.Array().Len()) + "]" + g.typeName(t.Array().Elem)
+	case KChan:
+		elem := g.typeName(t.Chan().Elem)
+		switch t.Chan().Direction() {
+		case RecvDir:
+			return "<-chan " + elem
+		case SendDir:
+			return "chan<- " + elem
+		}
+		if strings.HasPrefix(elem, "<-") {
+			elem = "(" + elem + ")"
+		}
+		return "chan " + elem
+	case KMap:
+		return "map[" + g.typeName(t.Map().Key) + "]" + g.typeName(t.Map().Elem)
+	case KPtr:
+		return "*" + g.typeName(t.Ptr().Elem)
+	case KSlice:
+		return "[]" + g.typeName(t.Slice().Elem)
+	case KStruct:
+		fields := t.Struct().Fields
+		if len(fields) == 0 {
+			return "struct{}"
+		}
+		s := "struct {"
+		for i, f := range fields {
+			if i != 0 {
+				s += ";"
+			}
+			if !f.Embedded() {
+				s += " " + f.Name()
+			}
+			s += " " + g.typeName(f.Type)
+			if f.HasTag() {
+				s += " " + strconv.Quote(string(f.Tag()))
+			}
+		}
+		return s + " }"
+	}
+	// Unnamed funcs and interfaces.
+	return t.String()
+}
+
+// helper returns the name of the helper variable for key,
+// declaring it the first time.
+func (g *golit) helper(key ptrKey, prefix, decl string) (name string, declared bool) {
+	if name, ok := g.names[key]; ok {
+		return name, false
+	}
+	g.vars++
+	name = prefix + strconv.Itoa(g.vars)
+	g.names[key] = name
+	g.decls = append(g.decls, name+" := "+decl)
+	return name, true
+}
+
+// expr returns the literal of v. In an interface, iface is true and
+// constants are converted to their type.
+func (g *golit) expr(v Value, iface bool) (string, error) {
+	t := v.Type
+	k := v.Kind()
+	switch k {
+	case KChan, KFunc, KInterface, KMap, KPtr, KSlice, KUnsafePointer:
+		if v.IsNil() {
+			if iface {
+				return "(" + g.typeName(t) + ")(nil)", nil
+			}
+			return "nil", nil
+		}
+	}
+
+	switch k {
+	case KInterface:
+		return g.expr(v.Surface().Elem(), true)
+
+	case KFunc, KUnsafePointer:
+		return "", errors.New("surface: GoLiteral of non-nil " + t.String())
+
+	case KChan:
+		return "make(" + g.typeName(t) + ", " + strconv.Itoa(v.Chan().Cap()) + ")", nil
+
+	case KPtr:
+		key := ptrKey{v.Pointer(), t}
+		elem := v.Ptr().Elem()
+		switch elem.Kind() {
+		case KArray, KMap, KSlice, KStruct:
+			if g.refs[key] <= 1 {
+				lit, err := g.expr(elem, false)
+				return "&" + lit, err
+			}
+		}
+		name, declared := g.helper(key, "p", "new("+g.typeName(elem.Type)+")")
+		if declared {
+			lit, err := g.expr(elem, false)
+			if err != nil {
+				return "", err
+			}
+			g.assigns = append(g.assigns, "*"+name+" = "+lit)
+		}
+		return name, nil
+
+	case KMap:
+		m := v.Map()
+		key := ptrKey{v.Pointer(), t}
+		if g.refs[key] <= 1 {
+			return g.entries(m, g.typeName(t))
+		}
+		name, declared := g.helper(key, "m", "make("+g.typeName(t)+")")
+		if declared {
+			for _, k := range sortKeys(m.Keys()) {
+				kl, el, err := g.entry(m, k)
+				if err != nil {
+					return "", err
+				}
+				g.assigns = append(g.assigns, name+"["+kl+"] = "+el)
+			}
+		}
+		return name, nil
+
+	case KSlice:
+		s := v.Slice()
+		elems := make([]string, s.Len())
+		for i := range elems {
+			lit, err := g.expr(s.Index(i), s.Type.Elem.Kind() == KInterface)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = lit
+		}
+		return composite(g.typeName(t), elems), nil
+
+	case KArray:
+		a := v.Array()
+		elems := make([]string, a.Len())
+		for i := range elems {
+			lit, err := g.expr(a.Index(i), a.Type.Elem.Kind() == KInterface)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = lit
+		}
+		return composite(g.typeName(t), elems), nil
+
+	case KStruct:
+		s := v.Struct()
+		var elems []string
+		for i, f := range s.Type.Fields {
+			fv := s.Field(i)
+			if fv.IsZero() {
+				continue
+			}
+			lit, err := g.expr(fv, f.Type.Kind() == KInterface)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, f.Name()+": "+lit)
+		}
+		return composite(g.typeName(t), elems), nil
+	}
+
+	// Constants in interfaces have their default type, and math.NaN
+	// or math.Inf are float64, other types need a conversion.
+	lit, def := basicLit(v)
+	if name := g.typeName(t); name != def && (iface || strings.Contains(lit, "math.")) {
+		return name + "(" + lit + ")", nil
+	}
+	return lit, nil
+}
+
+// entries returns the map literal of m.
+func (g *golit) entries(m Map, typ string) (string, error) {
+	keys := sortKeys(m.Keys())
+	elems := make([]string, len(keys))
+	for i, k := range keys {
+		kl, el, err := g.entry(m, k)
+		if err != nil {
+			return "", err
+		}
+		elems[i] = kl + ": " + el
+	}
+	return composite(typ, elems), nil
+}
+
+// entry returns the literals of the key k and its element in m.
+func (g *golit) entry(m Map, k Value) (kl, el string, err error) {
+	e := m.Index(k)
+	if !e.IsValid() {
+		// Only NaN keys are not found again.
+		return "", "", errors.New("surface: GoLiteral of " + m.Type.String() + " with NaN key")
+	}
+	if kl, err = g.expr(k, m.Type.Key.Kind() == KInterface); err == nil {
+		el, err = g.expr(e, m.Type.Elem.Kind() == KInterface)
+	}
+	return
+}
+
+// composite returns a composite literal with one element per line.
+func composite(typ string, elems []string) string {
+	if len(elems) == 0 {
+		return typ + "{}"
+	}
+	return typ + "{\n" + strings.Join(elems, ",\n") + ",\n}"
+}
+
+// basicLit returns the literal of a value of basic kind, and the name of
+// the default type of the constant.
+func basicLit(v Value) (lit, def string) {
+	switch k := v.Kind(); {
+	case k == KBool:
+		return strconv.FormatBool(v.Bool()), "bool"
+	case k == KString:
+		return strconv.Quote(v.String()), "string"
+	case isIntKind(k):
+		return strconv.FormatInt(v.Int64(), 10), "int"
+	case isUintKind(k):
+		return strconv.FormatUint(v.Uint64(), 10), ""
+	case k == KFloat32:
+		return floatLit(v.Float64(), 32), ""
+	case k == KFloat64:
+		return floatLit(v.Float64(), 64), "float64"
+	case k == KComplex64:
+		c := v.Complex64()
+		return "complex(" + floatLit(float64(real(c)), 32) + ", " + floatLit(float64(imag(c)), 32) + ")", ""
+	case k == KComplex128:
+		c := v.Complex128()
+		return "complex(" + floatLit(real(c), 64) + ", " + floatLit(imag(c), 64) + ")", "complex128"
+	}
+	panic(&ValueError{"surface.GoLiteral", v.Kind()})
+}
+
+// floatLit returns a float constant that is not mistaken for an integer.
+func floatLit(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
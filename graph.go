@@ -0,0 +1,201 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// An ObjectGraph is the graph of objects reachable from a value, see Graph.
+type ObjectGraph struct {
+	Nodes []GraphNode // Nodes[0] is the root
+	Edges []GraphEdge
+}
+
+// A GraphNode is a memory block of an ObjectGraph.
+type GraphNode struct {
+	ID   int     // index in ObjectGraph.Nodes
+	Addr uintptr // zero for a root that is not addressable
+	Type *Type
+	Size uintptr // bytes of the block, as counted by Footprint
+}
+
+// A GraphEdge is a reference from the object From to the object To.
+type GraphEdge struct {
+	From, To int
+	Label    string // path of the reference in From, e.g. Items[3].Next
+}
+
+// Graph returns the graph of the objects reachable from root.
+// The nodes are the root, the pointees of pointers, maps, slice backing
+// arrays, channels and values boxed in interfaces, keyed by address and type.
+// Struct fields and array elements are part of the object holding them,
+// the path to them is the label of the edges leaving that object.
+func Graph(root Value) *ObjectGraph {
+	g := &ObjectGraph{}
+	if !root.IsValid() {
+		return g
+	}
+	var addr uintptr
+	if root.flag&flagAddr != 0 {
+		addr = uintptr(root.val)
+	}
+	b := &graphBuilder{
+		g:     g,
+		ids:   make(map[ptrKey]int),
+		edges: make(map[GraphEdge]bool),
+	}
+	b.stack = append(b.stack, graphFrame{b.node(addr, root.Type, root.Type.Size), ""})
+	Walk(root, b)
+	return g
+}
+
+type graphFrame struct {
+	owner int    // node holding the value
+	base  string // path of the owner
+}
+
+type graphBuilder struct {
+	g     *ObjectGraph
+	ids   map[ptrKey]int
+	edges map[GraphEdge]bool
+	stack []graphFrame
+}
+
+// node returns the ID of the node at addr of type t, adding it the first time.
+func (b *graphBuilder) node(addr uintptr, t *Type, size uintptr) int {
+	key := ptrKey{addr, t}
+	if id, ok := b.ids[key]; ok && addr != 0 {
+		return id
+	}
+	id := len(b.g.Nodes)
+	b.ids[key] = id
+	b.g.Nodes = append(b.g.Nodes, GraphNode{id, addr, t, size})
+	return id
+}
+
+func (b *graphBuilder) Enter(n *Node) error {
+	top := b.stack[len(b.stack)-1]
+	v := n.Value
+	to := -1
+	switch v.Kind() {
+	case KPtr:
+		if !v.IsNil() {
+			elem := v.Type.Ptr().Elem
+			to = b.node(v.Pointer(), elem, elem.Size)
+		}
+
+	case KMap:
+		if !v.IsNil() {
+			to = b.node(v.Pointer(), v.Type, mapSize(v.Type.Map(), v.IWord()))
+		}
+
+	case KSlice:
+		if p := v.Pointer(); p != 0 {
+			s := v.Slice()
+			to = b.node(p, v.Type, uintptr(s.Cap())*s.Type.Elem.Size)
+		}
+
+	case KChan:
+		if !v.IsNil() {
+			to = b.node(v.Pointer(), v.Type, chanSize(v.IWord()))
+		}
+
+	case KInterface:
+		e := v.Surface()
+		if e.TargetType != nil && e.flag&flagIndir != 0 {
+			to = b.node(uintptr(e.val), e.TargetType, e.TargetType.Size)
+		}
+	}
+
+	if to < 0 {
+		b.stack = append(b.stack, top)
+		return nil
+	}
+	edge := GraphEdge{top.owner, to, strings.TrimPrefix(n.Path[len(top.base):], ".")}
+	if !b.edges[edge] {
+		b.edges[edge] = true
+		b.g.Edges = append(b.g.Edges, edge)
+	}
+	b.stack = append(b.stack, graphFrame{to, n.Path})
+	return nil
+}
+
+func (b *graphBuilder) Leave(n *Node) error {
+	b.stack = b.stack[:len(b.stack)-1]
+	return nil
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *ObjectGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph surface {\n")
+	bw.WriteString("\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		lines := []string{n.Type.String(), strconv.FormatUint(uint64(n.Size), 10) + " bytes"}
+		if n.Addr != 0 {
+			lines = append(lines, "0x"+strconv.FormatUint(uint64(n.Addr), 16))
+		}
+		bw.WriteString("\tn" + strconv.Itoa(n.ID) + " [label=" + dotQuote(lines...) + "];\n")
+	}
+	for _, e := range g.Edges {
+		bw.WriteString("\tn" + strconv.Itoa(e.From) + " -> n" + strconv.Itoa(e.To))
+		if e.Label != "" {
+			bw.WriteString(" [label=" + dotQuote(e.Label) + "]")
+		}
+		bw.WriteString(";\n")
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// dotQuote returns a DOT string of the lines.
+func dotQuote(lines ...string) string {
+	for i, s := range lines {
+		s = strings.Replace(s, `\`, `\\`, -1)
+		lines[i] = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return `"` + strings.Join(lines, `\n`) + `"`
+}
+
+type jsonNode struct {
+	ID   int    `json:"id"`
+	Addr string `json:"addr,omitempty"`
+	Type string `json:"type"`
+	Size uint64 `json:"size"`
+}
+
+type jsonEdge struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Label string `json:"label,omitempty"`
+}
+
+// MarshalJSON encodes the graph as
+//
+//	{"nodes":[{"id":0,"addr":"0xc2080001e0","type":"main.T","size":24}],
+//	 "edges":[{"from":0,"to":1,"label":"Items[3]"}]}
+func (g *ObjectGraph) MarshalJSON() ([]byte, error) {
+	var out struct {
+		Nodes []jsonNode `json:"nodes"`
+		Edges []jsonEdge `json:"edges"`
+	}
+	out.Nodes = make([]jsonNode, len(g.Nodes))
+	for i, n := range g.Nodes {
+		out.Nodes[i] = jsonNode{n.ID, "", n.Type.String(), uint64(n.Size)}
+		if n.Addr != 0 {
+			out.Nodes[i].Addr = "0x" + strconv.FormatUint(uint64(n.Addr), 16)
+		}
+	}
+	out.Edges = make([]jsonEdge, len(g.Edges))
+	for i, e := range g.Edges {
+		out.Edges[i] = jsonEdge{e.From, e.To, e.Label}
+	}
+	return json.Marshal(out)
+}
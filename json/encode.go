@@ -0,0 +1,968 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package json encodes values as JSON with the semantics of encoding/json,
+// driven by the field offsets of package surface instead of package reflect.
+//
+// Struct tags, embedded struct promotion, the Marshaler and TextMarshaler
+// interfaces of packages encoding/json and encoding, and the output are
+// those of encoding/json.Marshal. Options.IncludeUnexported also encodes
+// unexported struct fields.
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	stdjson "encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/ZxxLang/surface"
+)
+
+// Options configure the encoding.
+type Options struct {
+	// IncludeUnexported encodes unexported struct fields too,
+	// they follow the same tag rules as exported fields.
+	IncludeUnexported bool
+}
+
+// Marshal returns the JSON encoding of v, as encoding/json.Marshal.
+func Marshal(v interface{}) ([]byte, error) {
+	return Options{}.Marshal(v)
+}
+
+// Marshal returns the JSON encoding of v with the options o.
+func (o Options) Marshal(v interface{}) ([]byte, error) {
+	e := &encodeState{}
+	val := surface.ValueOf(v)
+	if !val.IsValid() {
+		return []byte("null"), nil
+	}
+	p, addr := addrOf(val)
+	if err := encoderOf(val.Type, o)(e, p, addr); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// An UnsupportedTypeError is returned by Marshal when attempting
+// to encode an unsupported value type.
+type UnsupportedTypeError struct {
+	Type *surface.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "json: unsupported type: " + e.Type.String()
+}
+
+// An UnsupportedValueError is returned by Marshal when attempting
+// to encode an unsupported value, such as a NaN or a cycle.
+type UnsupportedValueError struct {
+	Str string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "json: unsupported value: " + e.Str
+}
+
+// A MarshalerError is returned by Marshal when a MarshalJSON
+// or MarshalText method fails.
+type MarshalerError struct {
+	Type       *surface.Type
+	Err        error
+	sourceFunc string
+}
+
+func (e *MarshalerError) Error() string {
+	return "json: error calling " + e.sourceFunc + " for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+// startDetectingCyclesAfter is the number of nested pointers, maps, slices
+// and interfaces after which the encoder looks for cycles, as encoding/json.
+const startDetectingCyclesAfter = 1000
+
+type encodeState struct {
+	bytes.Buffer
+	depth   int
+	seen    map[seenKey]bool // entered past startDetectingCyclesAfter
+	scratch [64]byte
+}
+
+// A seenKey identifies a pointer, map or slice being encoded.
+type seenKey struct {
+	p   unsafe.Pointer
+	len int // slices of different lengths are different values
+	typ *surface.Type
+}
+
+// enter records the pointer, map or slice being encoded deeply,
+// it returns an error if it is already being encoded: a cycle.
+func (e *encodeState) enter(key seenKey) error {
+	if e.depth++; e.depth <= startDetectingCyclesAfter {
+		return nil
+	}
+	if e.seen == nil {
+		e.seen = make(map[seenKey]bool)
+	}
+	if e.seen[key] {
+		return &UnsupportedValueError{"encountered a cycle via " + key.typ.String()}
+	}
+	e.seen[key] = true
+	return nil
+}
+
+// leave ends the encoding of the value entered with key.
+func (e *encodeState) leave(key seenKey) {
+	if e.depth > startDetectingCyclesAfter {
+		delete(e.seen, key)
+	}
+	e.depth--
+}
+
+// An encoderFunc encodes the value at p. addr reports whether the value is
+// addressable, methods with pointer receivers are only called on those.
+type encoderFunc func(e *encodeState, p unsafe.Pointer, addr bool) error
+
+type encoderKey struct {
+	typ *surface.Type
+	o   Options
+}
+
+var encoders struct {
+	sync.RWMutex
+	m map[encoderKey]encoderFunc
+}
+
+// encoderOf returns the cached encoder of t.
+func encoderOf(t *surface.Type, o Options) encoderFunc {
+	encoders.RLock()
+	f := encoders.m[encoderKey{t, o}]
+	encoders.RUnlock()
+	if f != nil {
+		return f
+	}
+	interfaces.Do(initInterfaces)
+	encoders.Lock()
+	defer encoders.Unlock()
+	return newEncoder(t, o)
+}
+
+// newEncoder returns the encoder of t, building it if needed.
+// encoders must be locked.
+func newEncoder(t *surface.Type, o Options) encoderFunc {
+	key := encoderKey{t, o}
+	if f := encoders.m[key]; f != nil {
+		return f
+	}
+	if encoders.m == nil {
+		encoders.m = make(map[encoderKey]encoderFunc)
+	}
+	// Recursive types reach this encoder while it is built.
+	var f encoderFunc
+	encoders.m[key] = func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		return f(e, p, addr)
+	}
+	f = buildEncoder(t, o)
+	encoders.m[key] = f
+	return f
+}
+
+// addrOf returns the address of the data of v,
+// a copy if v is not addressable.
+func addrOf(v surface.Value) (p unsafe.Pointer, addr bool) {
+	if v.CanAddr() {
+		return unsafe.Pointer(v.UnsafeAddr()), true
+	}
+	c := surface.New(v.Type).Elem()
+	surface.Unrestricted(c).Set(surface.Unrestricted(v))
+	return unsafe.Pointer(c.UnsafeAddr()), false
+}
+
+// The interfaces of the marshaling methods, set by initInterfaces
+// before the first encoder is built.
+var (
+	interfaces        sync.Once
+	marshalerType     *surface.InterfaceType
+	textMarshalerType *surface.InterfaceType
+)
+
+func initInterfaces() {
+	marshalerType = surface.TypeOf((*stdjson.Marshaler)(nil)).Ptr().Elem.Surface()
+	textMarshalerType = surface.TypeOf((*encoding.TextMarshaler)(nil)).Ptr().Elem.Surface()
+}
+
+// implements reports whether values of type t implement it,
+// directly or through their address.
+func implements(t *surface.Type, it *surface.InterfaceType) (direct, viaAddr bool) {
+	if t.Kind() == surface.KInterface {
+		return false, false
+	}
+	if t.Implements(it) {
+		return true, false
+	}
+	pt := t.PtrToThis
+	return false, t.Kind() != surface.KPtr && pt != nil && pt.Implements(it)
+}
+
+func buildEncoder(t *surface.Type, o Options) encoderFunc {
+	if direct, viaAddr := implements(t, marshalerType); direct || viaAddr {
+		return condAddr(marshalerEncoder(t, viaAddr), viaAddr, t, o)
+	}
+	if direct, viaAddr := implements(t, textMarshalerType); direct || viaAddr {
+		return condAddr(textMarshalerEncoder(t, viaAddr), viaAddr, t, o)
+	}
+	return kindEncoder(t, o)
+}
+
+// condAddr returns enc if it does not need an address, else an encoder
+// calling enc for addressable values and the kind encoder for the others.
+func condAddr(enc encoderFunc, viaAddr bool, t *surface.Type, o Options) encoderFunc {
+	if !viaAddr {
+		return enc
+	}
+	other := kindEncoder(t, o)
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		if addr {
+			return enc(e, p, addr)
+		}
+		return other(e, p, addr)
+	}
+}
+
+// methodValue returns the value at p, or its address if viaAddr,
+// ready for a method call.
+func methodValue(t *surface.Type, p unsafe.Pointer, viaAddr bool) (interface{}, bool) {
+	if viaAddr {
+		return surface.NewAt(t, p).Interface(), true
+	}
+	if t.Kind() == surface.KPtr && *(*unsafe.Pointer)(p) == nil {
+		return nil, false
+	}
+	return surface.NewAt(t, p).Elem().Interface(), true
+}
+
+func marshalerEncoder(t *surface.Type, viaAddr bool) encoderFunc {
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		v, ok := methodValue(t, p, viaAddr)
+		if !ok {
+			e.WriteString("null")
+			return nil
+		}
+		b, err := v.(stdjson.Marshaler).MarshalJSON()
+		if err == nil {
+			var buf bytes.Buffer
+			if err = stdjson.Compact(&buf, b); err == nil {
+				stdjson.HTMLEscape(&e.Buffer, buf.Bytes())
+				return nil
+			}
+		}
+		return &MarshalerError{t, err, "MarshalJSON"}
+	}
+}
+
+func textMarshalerEncoder(t *surface.Type, viaAddr bool) encoderFunc {
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		v, ok := methodValue(t, p, viaAddr)
+		if !ok {
+			e.WriteString("null")
+			return nil
+		}
+		b, err := v.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return &MarshalerError{t, err, "MarshalText"}
+		}
+		e.string(string(b))
+		return nil
+	}
+}
+
+func kindEncoder(t *surface.Type, o Options) encoderFunc {
+	switch t.Kind() {
+	case surface.KBool:
+		return boolEncoder
+	case surface.KInt, surface.KInt8, surface.KInt16, surface.KInt32, surface.KInt64:
+		return intEncoder(t.Kind())
+	case surface.KUint, surface.KUint8, surface.KUint16, surface.KUint32, surface.KUint64, surface.KUintptr:
+		return uintEncoder(t.Kind())
+	case surface.KFloat32:
+		return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+			return e.float(float64(*(*float32)(p)), 32)
+		}
+	case surface.KFloat64:
+		return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+			return e.float(*(*float64)(p), 64)
+		}
+	case surface.KString:
+		return stringEncoder
+	case surface.KInterface:
+		return interfaceEncoder(t, o)
+	case surface.KPtr:
+		return ptrEncoder(t, o)
+	case surface.KStruct:
+		return structEncoder(t, o)
+	case surface.KMap:
+		return mapEncoder(t, o)
+	case surface.KSlice:
+		return sliceEncoder(t, o)
+	case surface.KArray:
+		return arrayEncoder(t, o)
+	}
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		return &UnsupportedTypeError{t}
+	}
+}
+
+func boolEncoder(e *encodeState, p unsafe.Pointer, addr bool) error {
+	if *(*bool)(p) {
+		e.WriteString("true")
+	} else {
+		e.WriteString("false")
+	}
+	return nil
+}
+
+func intEncoder(k surface.Kind) encoderFunc {
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		e.Write(strconv.AppendInt(e.scratch[:0], loadInt(k, p), 10))
+		return nil
+	}
+}
+
+func uintEncoder(k surface.Kind) encoderFunc {
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		e.Write(strconv.AppendUint(e.scratch[:0], loadUint(k, p), 10))
+		return nil
+	}
+}
+
+func loadInt(k surface.Kind, p unsafe.Pointer) int64 {
+	switch k {
+	case surface.KInt:
+		return int64(*(*int)(p))
+	case surface.KInt8:
+		return int64(*(*int8)(p))
+	case surface.KInt16:
+		return int64(*(*int16)(p))
+	case surface.KInt32:
+		return int64(*(*int32)(p))
+	}
+	return *(*int64)(p)
+}
+
+func loadUint(k surface.Kind, p unsafe.Pointer) uint64 {
+	switch k {
+	case surface.KUint:
+		return uint64(*(*uint)(p))
+	case surface.KUint8:
+		return uint64(*(*uint8)(p))
+	case surface.KUint16:
+		return uint64(*(*uint16)(p))
+	case surface.KUint32:
+		return uint64(*(*uint32)(p))
+	case surface.KUintptr:
+		return uint64(*(*uintptr)(p))
+	}
+	return *(*uint64)(p)
+}
+
+// float writes f as encoding/json does, like ES6 number to string conversion.
+func (e *encodeState) float(f float64, bits int) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return &UnsupportedValueError{strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(e.scratch[:0], f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	e.Write(b)
+	return nil
+}
+
+func stringEncoder(e *encodeState, p unsafe.Pointer, addr bool) error {
+	e.string(*(*string)(p))
+	return nil
+}
+
+const hex = "0123456789abcdef"
+
+// string writes s as a JSON string with the HTML escaping of encoding/json.
+func (e *encodeState) string(s string) {
+	e.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			e.WriteString(s[start:i])
+			switch b {
+			case '\\', '"':
+				e.WriteByte('\\')
+				e.WriteByte(b)
+			case '\b':
+				e.WriteString(`\b`)
+			case '\f':
+				e.WriteString(`\f`)
+			case '\n':
+				e.WriteString(`\n`)
+			case '\r':
+				e.WriteString(`\r`)
+			case '\t':
+				e.WriteString(`\t`)
+			default:
+				e.WriteString(`\u00`)
+				e.WriteByte(hex[b>>4])
+				e.WriteByte(hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			e.WriteString(s[start:i])
+			e.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are line terminators in JavaScript.
+		if c == '\u2028' || c == '\u2029' {
+			e.WriteString(s[start:i])
+			e.WriteString(`\u202`)
+			e.WriteByte(hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	e.WriteString(s[start:])
+	e.WriteByte('"')
+}
+
+func interfaceEncoder(t *surface.Type, o Options) encoderFunc {
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		et := surface.NewAt(t, p).Elem().Surface().TargetType
+		if et == nil {
+			e.WriteString("null")
+			return nil
+		}
+		// The data word of the interface holds a value that fits in it,
+		// else it points to the value. Neither is addressable.
+		q := unsafe.Pointer(uintptr(p) + unsafe.Sizeof(uintptr(0)))
+		if et.Size > unsafe.Sizeof(uintptr(0)) {
+			q = *(*unsafe.Pointer)(q)
+		}
+		e.depth++
+		err := encoderOf(et, o)(e, q, false)
+		e.depth--
+		return err
+	}
+}
+
+func ptrEncoder(t *surface.Type, o Options) encoderFunc {
+	elem := newEncoder(t.Ptr().Elem, o)
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		q := *(*unsafe.Pointer)(p)
+		if q == nil {
+			e.WriteString("null")
+			return nil
+		}
+		key := seenKey{q, 0, t}
+		if err := e.enter(key); err != nil {
+			return err
+		}
+		err := elem(e, q, true)
+		e.leave(key)
+		return err
+	}
+}
+
+func sliceEncoder(t *surface.Type, o Options) encoderFunc {
+	et := t.Slice().Elem
+	if et.Kind() == surface.KUint8 {
+		m, mp := implements(et, marshalerType)
+		tm, tmp := implements(et, textMarshalerType)
+		if !m && !mp && !tm && !tmp {
+			return bytesEncoder
+		}
+	}
+	elem := newEncoder(et, o)
+	size := et.Size
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		h := (*surface.SliceHeader)(p)
+		if h.Data == 0 {
+			e.WriteString("null")
+			return nil
+		}
+		key := seenKey{unsafe.Pointer(h.Data), h.Len, t}
+		if err := e.enter(key); err != nil {
+			return err
+		}
+		e.WriteByte('[')
+		for i := 0; i < h.Len; i++ {
+			if i != 0 {
+				e.WriteByte(',')
+			}
+			if err := elem(e, unsafe.Pointer(h.Data+uintptr(i)*size), true); err != nil {
+				e.leave(key)
+				return err
+			}
+		}
+		e.WriteByte(']')
+		e.leave(key)
+		return nil
+	}
+}
+
+func bytesEncoder(e *encodeState, p unsafe.Pointer, addr bool) error {
+	b := *(*[]byte)(p)
+	if b == nil {
+		e.WriteString("null")
+		return nil
+	}
+	e.WriteByte('"')
+	enc := base64.NewEncoder(base64.StdEncoding, e)
+	enc.Write(b)
+	enc.Close()
+	e.WriteByte('"')
+	return nil
+}
+
+func arrayEncoder(t *surface.Type, o Options) encoderFunc {
+	at := t.Array()
+	elem := newEncoder(at.Elem, o)
+	n, size := at.Len(), at.Elem.Size
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		e.WriteByte('[')
+		for i := 0; i < n; i++ {
+			if i != 0 {
+				e.WriteByte(',')
+			}
+			if err := elem(e, unsafe.Pointer(uintptr(p)+uintptr(i)*size), addr); err != nil {
+				return err
+			}
+		}
+		e.WriteByte(']')
+		return nil
+	}
+}
+
+type mapEntry struct {
+	key  string
+	elem unsafe.Pointer // in the map
+}
+
+type byKey []mapEntry
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byKey) Less(i, j int) bool { return s[i].key < s[j].key }
+
+func mapEncoder(t *surface.Type, o Options) encoderFunc {
+	mt := t.Map()
+	kt := mt.Key
+	switch kt.Kind() {
+	case surface.KString,
+		surface.KInt, surface.KInt8, surface.KInt16, surface.KInt32, surface.KInt64,
+		surface.KUint, surface.KUint8, surface.KUint16, surface.KUint32, surface.KUint64, surface.KUintptr:
+	default:
+		if direct, _ := implements(kt, textMarshalerType); !direct {
+			return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+				return &UnsupportedTypeError{t}
+			}
+		}
+	}
+	elem := newEncoder(mt.Elem, o)
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		m := surface.NewAt(t, p).Elem().Map()
+		if m.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		key := seenKey{*(*unsafe.Pointer)(p), 0, t}
+		if err := e.enter(key); err != nil {
+			return err
+		}
+		var err error
+		entries := make([]mapEntry, 0, m.Len())
+		m.Range(func(k, v unsafe.Pointer) bool {
+			var s string
+			if s, err = keyString(surface.NewAt(kt, k).Elem()); err != nil {
+				return false
+			}
+			entries = append(entries, mapEntry{s, v})
+			return true
+		})
+		if err == nil {
+			sort.Sort(byKey(entries))
+			err = encodeEntries(e, entries, elem)
+		}
+		e.leave(key)
+		return err
+	}
+}
+
+// encodeEntries encodes the JSON object of the sorted entries of a map.
+// The elements are read in the map, they are not addressable.
+func encodeEntries(e *encodeState, entries []mapEntry, elem encoderFunc) error {
+	e.WriteByte('{')
+	for i, kv := range entries {
+		if i != 0 {
+			e.WriteByte(',')
+		}
+		e.string(kv.key)
+		e.WriteByte(':')
+		if err := elem(e, kv.elem, false); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('}')
+	return nil
+}
+
+// keyString returns the JSON object name of the map key k.
+func keyString(k surface.Value) (string, error) {
+	switch k.Kind() {
+	case surface.KString:
+		return k.String(), nil
+	case surface.KInt, surface.KInt8, surface.KInt16, surface.KInt32, surface.KInt64:
+		if direct, _ := implements(k.Type, textMarshalerType); !direct {
+			return strconv.FormatInt(k.Int64(), 10), nil
+		}
+	case surface.KUint, surface.KUint8, surface.KUint16, surface.KUint32, surface.KUint64, surface.KUintptr:
+		if direct, _ := implements(k.Type, textMarshalerType); !direct {
+			return strconv.FormatUint(k.Uint64(), 10), nil
+		}
+	}
+	if k.Kind() == surface.KPtr && k.IsNil() {
+		return "", nil
+	}
+	b, err := surface.Unrestricted(k).Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return "", &MarshalerError{k.Type, err, "MarshalText"}
+	}
+	return string(b), nil
+}
+
+// A field is a struct field encoded as a JSON object member.
+type field struct {
+	name      string
+	nameJSON  []byte // encoded name followed by a colon
+	index     []int
+	depth     int
+	tagged    bool
+	omitEmpty bool
+	typ       *surface.Type
+	enc       encoderFunc
+
+	// The field is at offset from the struct, or after loading
+	// the pointers at the hops through embedded pointers.
+	hops   []uintptr
+	offset uintptr
+}
+
+// locate returns the address of the field in the struct at p,
+// nil if an embedded pointer is nil.
+func (f *field) locate(p unsafe.Pointer) unsafe.Pointer {
+	for _, off := range f.hops {
+		if p = *(*unsafe.Pointer)(unsafe.Pointer(uintptr(p) + off)); p == nil {
+			return nil
+		}
+	}
+	return unsafe.Pointer(uintptr(p) + f.offset)
+}
+
+func structEncoder(t *surface.Type, o Options) encoderFunc {
+	fields := structFields(t, o)
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		e.WriteByte('{')
+		first := true
+		for i := range fields {
+			f := &fields[i]
+			q := f.locate(p)
+			if q == nil || f.omitEmpty && isEmpty(f.typ, q) {
+				continue
+			}
+			if !first {
+				e.WriteByte(',')
+			}
+			first = false
+			e.Write(f.nameJSON)
+			if err := f.enc(e, q, addr || len(f.hops) != 0); err != nil {
+				return err
+			}
+		}
+		e.WriteByte('}')
+		return nil
+	}
+}
+
+// structFields returns the fields of t encoded by encoding/json, from the
+// fields and promoted fields listed by surface.InfoOf.
+func structFields(t *surface.Type, o Options) []field {
+	var fields []field
+	var skip []int // index of an embedded field whose fields are not promoted
+	for _, sf := range surface.InfoOf(t).Fields {
+		if skip != nil && hasPrefix(sf.Index, skip) {
+			continue
+		}
+		skip = nil
+
+		tag := sf.Tags["json"]
+		if tag.Name == "-" && len(tag.Options) == 0 {
+			skip = sf.Index
+			continue
+		}
+		name := tag.Name
+		if !isValidTag(name) {
+			name = ""
+		}
+		ft := sf.Type
+		if ft.Name() == "" && ft.Kind() == surface.KPtr {
+			ft = ft.Ptr().Elem
+		}
+		if sf.Embedded {
+			if name == "" && ft.Kind() == surface.KStruct {
+				// The promoted fields follow.
+				continue
+			}
+			skip = sf.Index
+			// As encoding/json, a named embedded struct is encoded
+			// even if its type is unexported.
+			if !sf.Exported && !o.IncludeUnexported && ft.Kind() != surface.KStruct {
+				continue
+			}
+		} else if !sf.Exported && !o.IncludeUnexported {
+			continue
+		}
+
+		f := field{
+			name:      name,
+			index:     sf.Index,
+			depth:     sf.Depth,
+			tagged:    name != "",
+			omitEmpty: tag.Has("omitempty"),
+			typ:       sf.Type,
+			offset:    sf.Offset,
+		}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		if sf.Indirect {
+			f.hops, f.offset = fieldHops(t, sf.Index)
+		}
+		if tag.Has("string") && quotable(ft) {
+			f.enc = quotedEncoder(sf.Type, o)
+		} else {
+			f.enc = newEncoder(sf.Type, o)
+		}
+		var e encodeState
+		e.string(f.name)
+		e.WriteByte(':')
+		f.nameJSON = e.Bytes()
+		fields = append(fields, f)
+	}
+	return dominantFields(fields)
+}
+
+// fieldHops returns the offsets of the embedded pointers leading to the
+// field at index in t, and the offset of the field after the last of them.
+func fieldHops(t *surface.Type, index []int) (hops []uintptr, offset uintptr) {
+	for _, i := range index {
+		if t.Kind() == surface.KPtr {
+			hops = append(hops, offset)
+			offset = 0
+			t = t.Ptr().Elem
+		}
+		sf := t.Struct().Fields[i]
+		offset += sf.Offset()
+		t = sf.Type
+	}
+	return
+}
+
+func hasPrefix(index, prefix []int) bool {
+	if len(index) < len(prefix) {
+		return false
+	}
+	for i, x := range prefix {
+		if index[i] != x {
+			return false
+		}
+	}
+	return true
+}
+
+// dominantFields applies the Go rules for visible fields, amended by
+// encoding/json: the least deep field of a name wins, a tagged field
+// wins over untagged ones as deep, other conflicts drop the name.
+func dominantFields(fields []field) []field {
+	sorted := make([]field, len(fields))
+	copy(sorted, fields)
+	sort.Sort(byName(sorted))
+
+	out := fields[:0]
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j].name == sorted[i].name {
+			j++
+		}
+		group := sorted[i:j]
+		if len(group) == 1 || group[0].depth != group[1].depth || group[0].tagged != group[1].tagged {
+			out = append(out, group[0])
+		}
+		i = j
+	}
+	sort.Sort(byIndex(out))
+	return out
+}
+
+// byName sorts fields by name, then least deep first, tagged first, and index.
+type byName []field
+
+func (s byName) Len() int      { return len(s) }
+func (s byName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool {
+	a, b := &s[i], &s[j]
+	if a.name != b.name {
+		return a.name < b.name
+	}
+	if a.depth != b.depth {
+		return a.depth < b.depth
+	}
+	if a.tagged != b.tagged {
+		return a.tagged
+	}
+	return byIndex(s).Less(i, j)
+}
+
+type byIndex []field
+
+func (s byIndex) Len() int      { return len(s) }
+func (s byIndex) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byIndex) Less(i, j int) bool {
+	a, b := s[i].index, s[j].index
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// isValidTag reports whether s is usable as a JSON name, as encoding/json.
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// quotable reports whether the ",string" option applies to values of type t.
+func quotable(t *surface.Type) bool {
+	switch t.Kind() {
+	case surface.KBool, surface.KString, surface.KFloat32, surface.KFloat64,
+		surface.KInt, surface.KInt8, surface.KInt16, surface.KInt32, surface.KInt64,
+		surface.KUint, surface.KUint8, surface.KUint16, surface.KUint32, surface.KUint64, surface.KUintptr:
+		return true
+	}
+	return false
+}
+
+// quotedEncoder encodes the value of a field with the ",string" option
+// inside a JSON string, a pointer to it is followed.
+func quotedEncoder(t *surface.Type, o Options) encoderFunc {
+	if t.Kind() == surface.KPtr {
+		elem := quotedEncoder(t.Ptr().Elem, o)
+		return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+			if q := *(*unsafe.Pointer)(p); q != nil {
+				return elem(e, q, true)
+			}
+			e.WriteString("null")
+			return nil
+		}
+	}
+	enc := newEncoder(t, o)
+	if direct, viaAddr := implements(t, marshalerType); direct || viaAddr {
+		return enc
+	}
+	if direct, viaAddr := implements(t, textMarshalerType); direct || viaAddr {
+		return enc
+	}
+	if t.Kind() == surface.KString {
+		return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+			var inner encodeState
+			inner.string(*(*string)(p))
+			e.string(inner.String())
+			return nil
+		}
+	}
+	return func(e *encodeState, p unsafe.Pointer, addr bool) error {
+		e.WriteByte('"')
+		if err := enc(e, p, addr); err != nil {
+			return err
+		}
+		e.WriteByte('"')
+		return nil
+	}
+}
+
+// isEmpty reports whether the value of type t at p is empty for omitempty.
+func isEmpty(t *surface.Type, p unsafe.Pointer) bool {
+	switch k := t.Kind(); k {
+	case surface.KArray:
+		return t.Array().Len() == 0
+	case surface.KMap:
+		return surface.NewAt(t, p).Elem().Map().Len() == 0
+	case surface.KSlice:
+		return (*surface.SliceHeader)(p).Len == 0
+	case surface.KString:
+		return (*surface.StringHeader)(p).Len == 0
+	case surface.KBool:
+		return !*(*bool)(p)
+	case surface.KInt, surface.KInt8, surface.KInt16, surface.KInt32, surface.KInt64:
+		return loadInt(k, p) == 0
+	case surface.KUint, surface.KUint8, surface.KUint16, surface.KUint32, surface.KUint64, surface.KUintptr:
+		return loadUint(k, p) == 0
+	case surface.KFloat32:
+		return *(*float32)(p) == 0
+	case surface.KFloat64:
+		return *(*float64)(p) == 0
+	case surface.KInterface, surface.KPtr:
+		return *(*unsafe.Pointer)(p) == nil
+	}
+	return false
+}
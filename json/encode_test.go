@@ -0,0 +1,245 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"github.com/achun/testing-want"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Inner struct {
+	A int
+	B string `json:"b,omitempty"`
+}
+
+type Named struct {
+	N int
+}
+
+type textKey int
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return []byte("k" + strings.Repeat("#", int(k))), nil
+}
+
+type ptrMarshaler struct {
+	X int
+}
+
+func (p *ptrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "ptr" : true }`), nil
+}
+
+type failing struct{}
+
+func (failing) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("failed")
+}
+
+type Record struct {
+	Inner
+	*Named
+	Named2  Named `json:"named"`
+	ID      int64 `json:"id,string"`
+	Price   *float64
+	Skip    string `json:"-"`
+	Dash    string `json:"-,"`
+	Empty   []int  `json:",omitempty"`
+	Bytes   []byte
+	Tags    map[string]int
+	Keys    map[textKey]bool
+	Any     interface{}
+	When    time.Time
+	Ptr     ptrMarshaler
+	Array   [2]float32
+	Html    string
+	private int
+}
+
+func newRecord() *Record {
+	price := 1.5
+	return &Record{
+		Inner:   Inner{A: 1},
+		Named:   &Named{N: 2},
+		Named2:  Named{N: 3},
+		ID:      42,
+		Price:   &price,
+		Skip:    "skip",
+		Dash:    "dash",
+		Bytes:   []byte("bytes"),
+		Tags:    map[string]int{"z": 1, "a": 2},
+		Keys:    map[textKey]bool{1: true, 2: false},
+		Any:     []interface{}{"x", 1e21, nil, map[string]interface{}{"y": 1e-7}},
+		When:    time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC),
+		Array:   [2]float32{0.1, 3},
+		Html:    "<a href=\"x\">& \x01</a>",
+		private: 7,
+	}
+}
+
+func TestMarshalCompatible(t *testing.T) {
+	wt := want.T(t)
+	values := []interface{}{
+		nil,
+		true,
+		"string",
+		-12,
+		uint8(200),
+		3.25,
+		float32(1e-7),
+		[]int(nil),
+		[]string{"a", "b"},
+		map[int]string{2: "b", 1: "a"},
+		newRecord(),
+		*newRecord(),
+		[]*Record{newRecord(), nil},
+	}
+	for _, v := range values {
+		expected, err := stdjson.Marshal(v)
+		wt.True(err == nil, err)
+		got, err := Marshal(v)
+		wt.True(err == nil, err)
+		wt.Equal(string(expected), string(got))
+	}
+}
+
+func TestMarshalUnexported(t *testing.T) {
+	wt := want.T(t)
+	type point struct {
+		X int
+		y int
+		z int `json:"-"`
+	}
+	b, err := Options{IncludeUnexported: true}.Marshal(point{1, 2, 3})
+	wt.True(err == nil, err)
+	wt.Equal(`{"X":1,"y":2}`, string(b))
+
+	b, err = Marshal(point{1, 2, 3})
+	wt.True(err == nil, err)
+	wt.Equal(`{"X":1}`, string(b))
+
+	type inner struct {
+		A int
+	}
+	type outer struct {
+		inner `json:"in"`
+		B     int
+	}
+	expected, err := stdjson.Marshal(outer{inner{1}, 2})
+	wt.True(err == nil, err)
+	b, err = Marshal(outer{inner{1}, 2})
+	wt.True(err == nil, err)
+	wt.Equal(string(expected), string(b))
+}
+
+func TestMarshalDeep(t *testing.T) {
+	wt := want.T(t)
+	type list struct {
+		Next *list
+	}
+	var l *list
+	for i := 0; i < 2000; i++ {
+		l = &list{l}
+	}
+	expected, err := stdjson.Marshal(l)
+	wt.True(err == nil, err)
+	got, err := Marshal(l)
+	wt.True(err == nil, err)
+	wt.Equal(string(expected), string(got))
+}
+
+func TestMarshalErrors(t *testing.T) {
+	wt := want.T(t)
+	type cyclic struct {
+		Next *cyclic
+	}
+	c := &cyclic{}
+	c.Next = c
+	m := map[string]interface{}{}
+	m["m"] = m
+	s := []interface{}{nil}
+	s[0] = s
+
+	for _, v := range []interface{}{
+		math.NaN(),
+		make(chan int),
+		failing{},
+		c,
+		m,
+		s,
+	} {
+		_, err := Marshal(v)
+		_, stderr := stdjson.Marshal(v)
+		wt.NotNil(err)
+		wt.NotNil(stderr)
+	}
+}
+
+type benchRow struct {
+	ID     int64             `json:"id"`
+	Name   string            `json:"name"`
+	Score  float64           `json:"score,omitempty"`
+	Tags   []string          `json:"tags"`
+	Attrs  map[string]string `json:"attrs"`
+	Active bool              `json:"active"`
+}
+
+var benchRows = func() []benchRow {
+	rows := make([]benchRow, 100)
+	for i := range rows {
+		rows[i] = benchRow{
+			ID:     int64(i),
+			Name:   "row",
+			Score:  float64(i) / 3,
+			Tags:   []string{"a", "b"},
+			Attrs:  map[string]string{"k": "v"},
+			Active: i%2 == 0,
+		}
+	}
+	return rows
+}()
+
+func BenchmarkMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(benchRows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStdMarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := stdjson.Marshal(benchRows); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalStruct(b *testing.B) {
+	b.ReportAllocs()
+	row := &benchRows[1]
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(row); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStdMarshalStruct(b *testing.B) {
+	b.ReportAllocs()
+	row := &benchRows[1]
+	for i := 0; i < b.N; i++ {
+		if _, err := stdjson.Marshal(row); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"errors"
+	"strconv"
+	"unsafe"
+)
+
+// A LayoutError describes why two types do not share the same memory layout.
+type LayoutError struct {
+	A, B   *Type  // the incompatible types
+	Path   string // location of the mismatch, relative to the compared types
+	Reason string
+}
+
+func (e *LayoutError) Error() string {
+	s := "surface: " + e.A.String() + " and " + e.B.String() + " are not layout compatible"
+	if e.Path != "" {
+		s += " at " + e.Path
+	}
+	return s + ": " + e.Reason
+}
+
+// Compatible returns nil if values of type a can be reinterpreted as values
+// of type b and vice versa. The types must agree in kind, size, alignment and
+// pointer layout, struct fields must have the same offsets, and element, field,
+// key and parameter types must be compatible, recursively.
+// Field names are not compared. Otherwise it returns a *LayoutError.
+func Compatible(a, b *Type) error {
+	return compatible(a, b, "", map[[2]*Type]bool{})
+}
+
+func compatible(a, b *Type, path string, seen map[[2]*Type]bool) error {
+	if a == b {
+		return nil
+	}
+	fail := func(reason string) error {
+		return &LayoutError{a, b, path, reason}
+	}
+	if a == nil || b == nil {
+		return fail("nil type")
+	}
+	key := [2]*Type{a, b}
+	if seen[key] {
+		// Recursive types, assume compatible until proven otherwise.
+		return nil
+	}
+	seen[key] = true
+
+	if a.Kind() != b.Kind() {
+		return fail("kind " + a.Kind().String() + " != " + b.Kind().String())
+	}
+	if a.Size != b.Size {
+		return fail("size " + utoa(a.Size) + " != " + utoa(b.Size))
+	}
+	if a.Align != b.Align {
+		return fail("align " + utoa(uintptr(a.Align)) + " != " + utoa(uintptr(b.Align)))
+	}
+	if k := a.Kind(); k != KArray && k != KStruct && a.HasPointers() != b.HasPointers() {
+		// The pointer words of arrays and structs are compared
+		// element by element and field by field below.
+		return fail("pointer layout differs")
+	}
+
+	switch a.Kind() {
+	case KArray:
+		at, bt := a.Array(), b.Array()
+		if at.Len() != bt.Len() {
+			return fail("array length " + strconv.Itoa(at.Len()) + " != " + strconv.Itoa(bt.Len()))
+		}
+		return compatible(at.Elem, bt.Elem, path+"[]", seen)
+
+	case KChan:
+		if a.Chan().Dir != b.Chan().Dir {
+			return fail("channel direction differs")
+		}
+		return compatible(a.Chan().Elem, b.Chan().Elem, path+"<-", seen)
+
+	case KFunc:
+		at, bt := a.Func(), b.Func()
+		if at.DotDotDot != bt.DotDotDot || len(at.In) != len(bt.In) || len(at.Out) != len(bt.Out) {
+			return fail("signature differs")
+		}
+		for i := range at.In {
+			if err := compatible(at.In[i], bt.In[i], path+"(in"+strconv.Itoa(i)+")", seen); err != nil {
+				return err
+			}
+		}
+		for i := range at.Out {
+			if err := compatible(at.Out[i], bt.Out[i], path+"(out"+strconv.Itoa(i)+")", seen); err != nil {
+				return err
+			}
+		}
+
+	case KInterface:
+		// The method tables of non-empty interfaces are indexed by method,
+		// they must list the same methods.
+		am, bm := a.Surface().Methods, b.Surface().Methods
+		if len(am) != len(bm) {
+			return fail("method set differs")
+		}
+		for i := range am {
+			if am[i].Name() != bm[i].Name() || am[i].PkgPath() != bm[i].PkgPath() || am[i].Type != bm[i].Type {
+				return fail("method set differs")
+			}
+		}
+
+	case KMap:
+		if err := compatible(a.Map().Key, b.Map().Key, path+"[key]", seen); err != nil {
+			return err
+		}
+		return compatible(a.Map().Elem, b.Map().Elem, path+"[]", seen)
+
+	case KPtr:
+		return compatible(a.Ptr().Elem, b.Ptr().Elem, path+"*", seen)
+
+	case KSlice:
+		return compatible(a.Slice().Elem, b.Slice().Elem, path+"[]", seen)
+
+	case KStruct:
+		af, bf := a.Struct().Fields, b.Struct().Fields
+		if len(af) != len(bf) {
+			return fail("field count " + strconv.Itoa(len(af)) + " != " + strconv.Itoa(len(bf)))
+		}
+		for i := range af {
+			fpath := path + "." + af[i].Name()
+			if af[i].offset != bf[i].offset {
+				return &LayoutError{a, b, fpath, "offset " + utoa(af[i].offset) + " != " + utoa(bf[i].offset)}
+			}
+			if err := compatible(af[i].Type, bf[i].Type, fpath, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func utoa(u uintptr) string {
+	return strconv.FormatUint(uint64(u), 10)
+}
+
+// Reinterpret returns a Value of type t sharing the memory of v.
+// Nothing is copied, writes through one are visible through the other.
+// It returns an error if the types are not Compatible.
+func Reinterpret(v Value, t *Type) (Value, error) {
+	if v.flag == 0 {
+		panic(&ValueError{"surface.Reinterpret", KInvalid})
+	}
+	if v.flag&flagMethod != 0 {
+		return Value{}, &LayoutError{v.Type, t, "", "method value"}
+	}
+	if err := Compatible(v.Type, t); err != nil {
+		return Value{}, err
+	}
+	fl := v.flag&(flagRO|flagIndir|flagAddr) | flag(t.Kind())<<flagKindShift
+	return Value{t, sur{v.val, v.scalar, fl, unsafe.Pointer(t)}}, nil
+}
+
+// ValueAt returns a read-only, addressable Value of type t for the memory at p.
+// The caller guarantees that p points to memory holding a value of type t
+// which stays alive while the Value is used. ValueAt(nil, t) returns the zero Value.
+// It panics if t contains pointers or if p is not aligned for t,
+// use NewAt for memory allocated by Go.
+func ValueAt(p unsafe.Pointer, t *Type) Value {
+	if t == nil {
+		panic("surface: ValueAt of nil type")
+	}
+	if t.HasPointers() {
+		panic("surface: ValueAt of type with pointers " + t.String())
+	}
+	if p == nil {
+		return Value{}
+	}
+	if t.Align != 0 && uintptr(p)%uintptr(t.Align) != 0 {
+		panic("surface: ValueAt of " + t.String() + " at misaligned address")
+	}
+	fl := flagRO | flagIndir | flagAddr | flag(t.Kind())<<flagKindShift
+	return Value{t, sur{p, 0, fl, unsafe.Pointer(t)}}
+}
+
+// zeroSized is the memory of zero-size values overlaid on empty slices.
+var zeroSized [0]uint64
+
+// Overlay returns a read-only, addressable Value of type t over the first
+// t.Size bytes of b, for example a record in a memory-mapped file.
+// Nothing is decoded or copied. It returns an error if b is too short,
+// if &b[0] is not aligned for t, or if t contains pointers: the garbage
+// collector must never see pointers in plain bytes.
+func Overlay(b []byte, t *Type) (Value, error) {
+	if t == nil {
+		return Value{}, errors.New("surface: Overlay of nil type")
+	}
+	if t.HasPointers() {
+		return Value{}, errors.New("surface: Overlay of type with pointers " + t.String())
+	}
+	if uintptr(len(b)) < t.Size {
+		return Value{}, errors.New("surface: Overlay of " + t.String() + " needs " +
+			utoa(t.Size) + " bytes, have " + strconv.Itoa(len(b)))
+	}
+	if len(b) == 0 {
+		// t.Size is 0, nothing is read through the address.
+		return ValueAt(unsafe.Pointer(&zeroSized), t), nil
+	}
+	p := unsafe.Pointer(&b[0])
+	if t.Align != 0 && uintptr(p)%uintptr(t.Align) != 0 {
+		return Value{}, errors.New("surface: Overlay of " + t.String() + " at misaligned address")
+	}
+	return ValueAt(p, t), nil
+}
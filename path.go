@@ -0,0 +1,149 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Paths name a location inside a value graph with Go syntax, relative to the root:
+//
+//	Users[3].Profile.Name
+//	Cache["k"]
+//
+// Pointers and interfaces are dereferenced implicitly, as in Go selectors.
+
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func joinIndex(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func joinKey(path string, key Value) string {
+	return path + "[" + formatKey(key) + "]"
+}
+
+// maxSummary is the length formatKey cuts the summaries of composite values to.
+const maxSummary = 64
+
+// formatKey returns the map key as a Go literal where possible,
+// composite values as a short summary in the format of fmt's %v.
+func formatKey(key Value) string {
+	switch k := key.Kind(); {
+	case k == KInterface:
+		return formatKey(key.Surface().Elem())
+	case k == KString:
+		return strconv.Quote(key.String())
+	case k == KBool:
+		return strconv.FormatBool(key.Bool())
+	case isIntKind(k):
+		return strconv.FormatInt(key.Int64(), 10)
+	case isUintKind(k):
+		return strconv.FormatUint(key.Uint64(), 10)
+	case isFloatKind(k):
+		return strconv.FormatFloat(key.Float64(), 'g', -1, 64)
+	case k == KPtr || k == KChan || k == KUnsafePointer:
+		return "0x" + strconv.FormatUint(uint64(key.Pointer()), 16)
+	case k == KInvalid:
+		return "nil"
+	case key.CanInterface():
+		return summary(fmt.Sprintf("%v", key.Interface()))
+	}
+	return key.Type.String()
+}
+
+// summary cuts s to about maxSummary bytes, on a rune boundary.
+func summary(s string) string {
+	if len(s) <= maxSummary {
+		return s
+	}
+	i := maxSummary
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i] + "..."
+}
+
+// matchPath reports whether path matches the pattern,
+// where the index "[*]" in pattern matches any index or map key of path.
+func matchPath(pattern, path string) bool {
+	for pattern != "" && path != "" {
+		if len(pattern) >= 3 && pattern[:3] == "[*]" && path[0] == '[' {
+			i := closeBracket(path)
+			if i < 0 {
+				return false
+			}
+			pattern, path = pattern[3:], path[i+1:]
+			continue
+		}
+		if pattern[0] != path[0] {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return pattern == path
+}
+
+// closeBracket returns the index of the ']' closing the '[' at path[0],
+// skipping quoted map keys.
+func closeBracket(path string) int {
+	quoted := false
+	for i := 1; i < len(path); i++ {
+		switch c := path[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// sortKeys sorts map keys for a stable output: numbers and strings
+// in their natural order, other keys by their formatted form.
+func sortKeys(keys []Value) []Value {
+	sort.Sort(keySorter(keys))
+	return keys
+}
+
+type keySorter []Value
+
+func (s keySorter) Len() int      { return len(s) }
+func (s keySorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s keySorter) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	if a.Kind() == KInterface {
+		a = a.Surface().Elem()
+	}
+	if b.Kind() == KInterface {
+		b = b.Surface().Elem()
+	}
+	ak, bk := a.Kind(), b.Kind()
+	switch {
+	case ak != bk:
+		return ak < bk
+	case ak == KString:
+		return a.String() < b.String()
+	case isIntKind(ak):
+		return a.Int64() < b.Int64()
+	case isUintKind(ak):
+		return a.Uint64() < b.Uint64()
+	case isFloatKind(ak):
+		return a.Float64() < b.Float64()
+	case ak == KBool:
+		return !a.Bool() && b.Bool()
+	}
+	return formatKey(a) < formatKey(b)
+}
@@ -0,0 +1,210 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A Match is a value found by Query.
+type Match struct {
+	Path  string // concrete path of Value, wildcards replaced by the index or key
+	Value Value
+}
+
+// Query returns the values of v selected by the path expression expr,
+// in the path syntax of Walk:
+//
+//	Orders[2].Items[*].SKU
+//	Cache["k"].Hits
+//	ByID[42]
+//
+// A selector is a field name, unexported ones included, an index of an
+// array or slice, a map key written as a string or integer literal, or the
+// wildcard [*] matching every element or map entry, in key order.
+// Pointers and interfaces are dereferenced implicitly before each selector.
+// Selectors not applicable to a value, missing map keys, out of range
+// indexes and nil pointers give no match, Query only fails on syntax errors.
+func Query(v Value, expr string) ([]Match, error) {
+	steps, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	matches := []Match{{"", v}}
+	for _, s := range steps {
+		var next []Match
+		for _, m := range matches {
+			next = s.apply(next, m)
+		}
+		matches = next
+	}
+	return matches, nil
+}
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex          // [2], an index or an integer map key
+	stepKey            // ["k"]
+	stepAny            // [*]
+)
+
+type queryStep struct {
+	kind  stepKind
+	name  string // field name or string key
+	index int64
+}
+
+func queryError(expr, reason string) error {
+	return errors.New("surface: Query " + strconv.Quote(expr) + ": " + reason)
+}
+
+// parseQuery splits expr into selectors.
+func parseQuery(expr string) ([]queryStep, error) {
+	var steps []queryStep
+	s := expr
+	for s != "" {
+		switch {
+		case s[0] == '[':
+			i := closeBracket(s)
+			if i < 0 {
+				return nil, queryError(expr, "missing ]")
+			}
+			lit := s[1:i]
+			s = s[i+1:]
+			switch {
+			case lit == "*":
+				steps = append(steps, queryStep{kind: stepAny})
+			case lit != "" && lit[0] == '"':
+				key, err := strconv.Unquote(lit)
+				if err != nil {
+					return nil, queryError(expr, "bad key "+lit)
+				}
+				steps = append(steps, queryStep{kind: stepKey, name: key})
+			default:
+				n, err := strconv.ParseInt(lit, 10, 64)
+				if err != nil {
+					return nil, queryError(expr, "bad index "+strconv.Quote(lit))
+				}
+				steps = append(steps, queryStep{kind: stepIndex, index: n})
+			}
+
+		case s[0] == '.' && len(steps) != 0:
+			s = s[1:]
+			fallthrough
+
+		default:
+			i := 0
+			for i < len(s) && s[i] != '.' && s[i] != '[' {
+				i++
+			}
+			if i == 0 {
+				return nil, queryError(expr, "missing field name")
+			}
+			steps = append(steps, queryStep{kind: stepField, name: s[:i]})
+			s = s[i:]
+		}
+	}
+	return steps, nil
+}
+
+// deref dereferences pointers and interfaces, it returns
+// the zero Value for nil.
+func deref(v Value) Value {
+	for v.IsValid() {
+		switch v.Kind() {
+		case KPtr:
+			if v.IsNil() {
+				return Value{}
+			}
+			v = v.Ptr().Elem()
+		case KInterface:
+			v = v.Surface().Elem()
+		default:
+			return v
+		}
+	}
+	return v
+}
+
+// apply appends the matches of s in m.Value to out.
+func (s queryStep) apply(out []Match, m Match) []Match {
+	v := deref(m.Value)
+	switch v.Kind() {
+	case KStruct:
+		if s.kind != stepField {
+			break
+		}
+		st := v.Struct()
+		for i, f := range st.Type.Fields {
+			if f.Name() == s.name {
+				out = append(out, Match{joinField(m.Path, s.name), st.Field(i)})
+				break
+			}
+		}
+
+	case KArray, KSlice:
+		n := 0
+		var index func(int) Value
+		if v.Kind() == KArray {
+			a := v.Array()
+			n, index = a.Len(), a.Index
+		} else {
+			sl := v.Slice()
+			n, index = sl.Len(), sl.Index
+		}
+		switch s.kind {
+		case stepIndex:
+			if s.index >= 0 && s.index < int64(n) {
+				out = append(out, Match{joinIndex(m.Path, int(s.index)), index(int(s.index))})
+			}
+		case stepAny:
+			for i := 0; i < n; i++ {
+				out = append(out, Match{joinIndex(m.Path, i), index(i)})
+			}
+		}
+
+	case KMap:
+		mp := v.Map()
+		var key Value
+		switch s.kind {
+		case stepAny:
+			for _, key := range sortKeys(mp.Keys()) {
+				out = append(out, Match{joinKey(m.Path, key), mp.Index(key)})
+			}
+			return out
+		case stepKey:
+			key = ValueOf(s.name)
+		case stepIndex:
+			key = ValueOf(s.index)
+		default:
+			return out
+		}
+		switch k := mp.Type.Key.Kind(); {
+		case k == KInterface:
+			if s.kind == stepIndex {
+				key = ValueOf(int(s.index))
+			}
+		case s.kind == stepKey && k == KString,
+			s.kind == stepIndex && isIntKind(k),
+			s.kind == stepIndex && isUintKind(k) && s.index >= 0:
+			key, _ = key.Convert(mp.Type.Key)
+			if s.kind == stepIndex && formatKey(key) != strconv.FormatInt(s.index, 10) {
+				return out // overflows the key type
+			}
+		default:
+			return out
+		}
+		if e := mp.Index(key); e.IsValid() {
+			out = append(out, Match{joinKey(m.Path, key), e})
+		}
+	}
+	return out
+}
@@ -64,3 +64,349 @@ func (v Map) Keys() []Value {
 	}
 	return ret
 }
+
+// Range calls f with pointers to the key and the element of each entry
+// of the map v, in unspecified order, until f returns false. Entries with
+// NaN keys are included. The memory belongs to the map: it must not be
+// written through the pointers, and they are only valid until the map
+// is written.
+func (v Map) Range(f func(key, elem unsafe.Pointer) bool) {
+	if v.IsNil() {
+		return
+	}
+	var it _Hiter
+	for mapiterinit(&v.Type.Type, v.IWord(), &it); it.key != nil; mapiternext(&it) {
+		if !f(it.key, it.value) {
+			return
+		}
+	}
+}
+
+// entries returns the keys and the elements of the map v in the same order,
+// entries with NaN keys included, which Index cannot find.
+func (v Map) entries() (keys, elems []Value) {
+	n := v.Len()
+	keys, elems = make([]Value, 0, n), make([]Value, 0, n)
+	it := v.ToValue().MapRange()
+	for it.Next() {
+		keys = append(keys, FromValue(it.Key()))
+		elems = append(elems, FromValue(it.Value()))
+	}
+	return
+}
+
+// SetIndex sets the element associated with key in the map v to elem.
+// It panics if v, key or elem was obtained by accessing unexported struct fields,
+// if key is not assignable to v.Type.Key, elem is not assignable to v.Type.Elem,
+// or if v is a nil map.
+func (v Map) SetIndex(key, elem Value) {
+	v.mustBeExported()
+	key.mustBeExported()
+	elem.mustBeExported()
+	v.checkKey(key)
+	if !elem.Type.AssignableTo(v.Type.Elem) {
+		panic("surface: SetIndex using " + elem.Type.String() + " as type " + v.Type.Elem.String())
+	}
+	if v.IsNil() {
+		panic("surface: assignment to entry in nil map")
+	}
+	mapassign(&v.Type.Type, v.IWord(), v.Type.Key.operand(&key), v.Type.Elem.operand(&elem))
+}
+
+// Delete deletes the element associated with key from the map v.
+// Delete of a missing key or on a nil map is a no-op.
+func (v Map) Delete(key Value) {
+	v.mustBeExported()
+	key.mustBeExported()
+	v.checkKey(key)
+	if v.IsNil() {
+		return
+	}
+	mapdelete(&v.Type.Type, v.IWord(), v.Type.Key.operand(&key))
+}
+
+// Clear deletes all elements of the map v, including those with NaN keys
+// which cannot be deleted by key.
+func (v Map) Clear() {
+	v.mustBeExported()
+	if v.IsNil() {
+		return
+	}
+	mapclear(&v.Type.Type, v.IWord())
+}
+
+// operand returns a pointer to the memory of x as a value of type t,
+// x must be assignable to t. A direct value is addressed in place in x,
+// a value of another type, such as a concrete value stored in an interface,
+// is converted into a new value first.
+func (t *Type) operand(x *Value) unsafe.Pointer {
+	if x.Type != t {
+		p := New(t).Elem()
+		p.Set(*x)
+		return p.val
+	}
+	if x.flag&flagIndir != 0 {
+		return x.val
+	}
+	return unsafe.Pointer(&x.val)
+}
+
+func (v Map) checkKey(key Value) {
+	if !key.Type.AssignableTo(v.Type.Key) {
+		panic("surface: map key " + key.Type.String() + " is not assignable to type " + v.Type.Key.String())
+	}
+}
+
+// Send sends x on the channel v, blocking until x can be sent.
+// It panics if v is a receive-only channel or if x is not assignable
+// to the element type of v.
+func (v Chan) Send(x Value) {
+	v.checkSend(x)
+	v.ToValue().Send(x.ToValue())
+}
+
+// TrySend attempts to send x on the channel v but will not block.
+// It reports whether the value was sent.
+func (v Chan) TrySend(x Value) bool {
+	v.checkSend(x)
+	return v.ToValue().TrySend(x.ToValue())
+}
+
+// Recv receives and returns a value from the channel v, blocking until
+// a value is ready. The boolean value ok is true if the value x
+// corresponds to a send on the channel, false if it is a zero value
+// received because the channel is closed.
+// It panics if v is a send-only channel.
+func (v Chan) Recv() (x Value, ok bool) {
+	v.checkRecv()
+	rx, ok := v.ToValue().Recv()
+	return FromValue(rx), ok
+}
+
+// TryRecv attempts to receive a value from the channel v but will not block.
+// If the receive delivers a value, x is the transferred value and ok is true.
+// If the receive cannot finish without blocking, x is the zero Value and ok is false.
+// If the channel is closed, x is the zero value for the channel's element type and ok is false.
+func (v Chan) TryRecv() (x Value, ok bool) {
+	v.checkRecv()
+	rx, ok := v.ToValue().TryRecv()
+	return FromValue(rx), ok
+}
+
+// Close closes the channel v.
+// It panics if v is a receive-only channel.
+func (v Chan) Close() {
+	v.mustBeExported()
+	if v.Type.Direction()&SendDir == 0 {
+		panic("surface: close of receive-only channel")
+	}
+	v.ToValue().Close()
+}
+
+func (v Chan) checkSend(x Value) {
+	v.mustBeExported()
+	x.mustBeExported()
+	if v.Type.Direction()&SendDir == 0 {
+		panic("surface: send on recv-only channel")
+	}
+	if !x.Type.AssignableTo(v.Type.Elem) {
+		panic("surface: send of " + x.Type.String() + " on channel of " + v.Type.Elem.String())
+	}
+}
+
+func (v Chan) checkRecv() {
+	v.mustBeExported()
+	if v.Type.Direction()&RecvDir == 0 {
+		panic("surface: recv on send-only channel")
+	}
+}
+
+// A SelectDir describes the communication direction of a select case.
+type SelectDir int
+
+const (
+	_             SelectDir = iota
+	SelectSend              // case Chan <- Send
+	SelectRecv              // case <-Chan:
+	SelectDefault           // default
+)
+
+// A SelectCase describes a single case in a select operation.
+// The kind of case depends on Dir, the communication direction.
+//
+// If Dir is SelectDefault, the case represents a default case.
+// Chan and Send must be zero Values.
+//
+// If Dir is SelectSend, the case represents a send operation.
+// Normally Chan's underlying value must be a channel, and Send's underlying value must be
+// assignable to the channel's element type. As a special case, if Chan is a zero Value,
+// then the case is ignored, and the field Send will also be ignored and may be either zero
+// or non-zero.
+//
+// If Dir is SelectRecv, the case represents a receive operation.
+// Normally Chan's underlying value must be a channel and Send must be a zero Value.
+// If Chan is a zero Value, then the case is ignored, but Send must still be a zero Value.
+// When a receive operation is selected, the received Value is returned by Select.
+type SelectCase struct {
+	Dir  SelectDir // direction of case
+	Chan Chan      // channel to use (for send or receive)
+	Send Value     // value to send (for send)
+}
+
+// Select executes a select operation described by the list of cases.
+// Like the Go select statement, it blocks until at least one of the cases
+// can proceed, makes a uniform pseudo-random choice,
+// and then executes that case. It returns the index of the chosen case
+// and, if that case was a receive operation, the value received and a
+// boolean indicating whether the value corresponds to a send on the channel
+// (as opposed to a zero value received because the channel is closed).
+func Select(cases []SelectCase) (chosen int, recv Value, recvOK bool) {
+	rc := make([]reflect.SelectCase, len(cases))
+	for i, c := range cases {
+		switch c.Dir {
+		case SelectDefault:
+		case SelectSend:
+			if c.Chan.flag != 0 {
+				c.Chan.checkSend(c.Send)
+			}
+		case SelectRecv:
+			if c.Send.flag != 0 {
+				panic("surface: Select with recv case has Send value")
+			}
+			if c.Chan.flag != 0 {
+				c.Chan.checkRecv()
+			}
+		default:
+			panic("surface: Select with invalid case direction")
+		}
+		rc[i] = reflect.SelectCase{
+			Dir:  reflect.SelectDir(c.Dir),
+			Chan: c.Chan.ToValue(),
+			Send: c.Send.ToValue(),
+		}
+	}
+	chosen, rx, recvOK := reflect.Select(rc)
+	return chosen, FromValue(rx), recvOK
+}
+
+// toType returns the reflect.Type of t, nil if t is nil.
+func toType(t *Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	var i interface{}
+	(*EmptyInterface)(unsafe.Pointer(&i)).Type = t
+	return reflect.TypeOf(i)
+}
+
+// AssignableTo reports whether a value of the type t is assignable to type u.
+func (t *Type) AssignableTo(u *Type) bool {
+	if t == nil || u == nil {
+		return false
+	}
+	return t == u || toType(t).AssignableTo(toType(u))
+}
+
+// call calls v through package reflect, args have been checked by Call.
+func (v Func) call(args []Value, slice bool) []Value {
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		in[i] = arg.ToValue()
+	}
+	var out []reflect.Value
+	if slice {
+		out = v.ToValue().CallSlice(in)
+	} else {
+		out = v.ToValue().Call(in)
+	}
+	ret := make([]Value, len(out))
+	for i := 0; i < len(out); i++ {
+		ret[i] = FromValue(out[i])
+	}
+	return ret
+}
+
+// New returns a Ptr representing a pointer to a new zero value
+// for the specified type.
+func New(t *Type) Ptr {
+	if t == nil {
+		panic("surface: New(nil)")
+	}
+	pt := ptrTo(t)
+	fl := flag(KPtr) << flagKindShift
+	return Value{pt, sur{unsafe_New(t), 0, fl, unsafe.Pointer(pt)}}.Ptr()
+}
+
+// NewAt returns a Ptr representing a pointer to a value of the specified type,
+// using p as that pointer.
+func NewAt(t *Type, p unsafe.Pointer) Ptr {
+	if t == nil {
+		panic("surface: NewAt(nil)")
+	}
+	pt := ptrTo(t)
+	fl := flag(KPtr) << flagKindShift
+	return Value{pt, sur{p, 0, fl, unsafe.Pointer(pt)}}.Ptr()
+}
+
+// ptrTo returns the type of pointers to t.
+func ptrTo(t *Type) *Type {
+	if t.PtrToThis != nil {
+		return t.PtrToThis
+	}
+	return TypeOf(reflect.Zero(reflect.PtrTo(toType(t))).Interface())
+}
+
+// MakeSlice creates a new zero-initialized slice value
+// for the specified slice type, length, and capacity.
+func MakeSlice(t *Type, len, cap int) Slice {
+	t.mustBe(KSlice)
+	if len < 0 || cap < len {
+		panic("surface: MakeSlice len or cap out of range")
+	}
+	s := &_Slice{unsafe_NewArray(t.Slice().Elem, cap), len, cap}
+	fl := flagIndir | flag(KSlice)<<flagKindShift
+	return Value{t, sur{unsafe.Pointer(s), 0, fl, unsafe.Pointer(t)}}.Slice()
+}
+
+// MakeMap creates a new map of the specified type.
+func MakeMap(t *Type) Map {
+	return MakeMapWithSize(t, 0)
+}
+
+// MakeMapWithSize creates a new map of the specified type
+// with initial space for approximately n elements.
+func MakeMapWithSize(t *Type, n int) Map {
+	t.mustBe(KMap)
+	if n < 0 {
+		panic("surface: MakeMapWithSize negative size")
+	}
+	return FromValue(reflect.MakeMapWithSize(toType(t), n)).Map()
+}
+
+// MakeChan creates a new channel with the specified type and buffer size.
+func MakeChan(t *Type, buffer int) Chan {
+	t.mustBe(KChan)
+	if buffer < 0 {
+		panic("surface: MakeChan negative buffer size")
+	}
+	if ChanDir(t.Chan().Dir) != BothDir {
+		panic("surface: MakeChan of one-way channel type")
+	}
+	return FromValue(reflect.MakeChan(toType(t), buffer)).Chan()
+}
+
+// typedmemmove copies a value of type t to dst from src through the runtime,
+// with the write barriers the concurrent garbage collector needs when
+// the value contains pointers.
+func typedmemmove(t *Type, dst, src unsafe.Pointer) {
+	rt := toType(t)
+	reflect.NewAt(rt, dst).Elem().Set(reflect.NewAt(rt, src).Elem())
+}
+
+// typedslicecopy copies the elements of src into dst through the runtime,
+// keeping the garbage collector informed of the pointers being written.
+func typedslicecopy(dst, src Slice) int {
+	dst.flag &^= flagRO
+	src.flag &^= flagRO
+	return reflect.Copy(dst.ToValue(), src.ToValue())
+}
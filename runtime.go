@@ -13,6 +13,8 @@
 
 package surface
 
+import "unsafe"
+
 // Via /pkg/runtime/runtime.h
 type _Lock struct {
 	key uintptr
@@ -61,6 +63,59 @@ type _Hmap struct {
 	nevacuate  uintptr
 }
 
+// Via /pkg/runtime/hashmap.c
+type _Hiter struct {
+	key         unsafe.Pointer // nil at the end of the iteration
+	value       unsafe.Pointer
+	t           unsafe.Pointer
+	h           unsafe.Pointer
+	endbucket   uintptr
+	wrapped     bool
+	B           uint8
+	buckets     unsafe.Pointer
+	bucket      uintptr
+	bptr        unsafe.Pointer
+	i           uintptr
+	checkBucket int
+}
+
+// Via /pkg/runtime/runtime.h
+type _Slice struct {
+	array unsafe.Pointer
+	len   int
+	cap   int
+}
+
+// Via /pkg/reflect/value.go, implemented in package runtime.
+
+//go:linkname unsafe_New reflect.unsafe_New
+func unsafe_New(t *Type) unsafe.Pointer
+
+//go:linkname unsafe_NewArray reflect.unsafe_NewArray
+func unsafe_NewArray(t *Type, n int) unsafe.Pointer
+
+// The map functions copy the key and the element out of the memory
+// they point to, neither escapes.
+
+//go:noescape
+//go:linkname mapassign reflect.mapassign0
+func mapassign(t *Type, m IWord, key, val unsafe.Pointer)
+
+//go:noescape
+//go:linkname mapdelete reflect.mapdelete
+func mapdelete(t *Type, m IWord, key unsafe.Pointer)
+
+//go:linkname mapclear reflect.mapclear
+func mapclear(t *Type, m IWord)
+
+//go:noescape
+//go:linkname mapiterinit reflect.mapiterinit
+func mapiterinit(t *Type, m IWord, it *_Hiter)
+
+//go:noescape
+//go:linkname mapiternext reflect.mapiternext
+func mapiternext(it *_Hiter)
+
 func maplen(m IWord) int {
 	if m == nil {
 		return 0
@@ -84,3 +139,40 @@ func chanlen(ch IWord) int {
 	p := (*_Hchan)(ch)
 	return int(p.qcount)
 }
+
+func chanclosed(ch IWord) bool {
+	if ch == nil {
+		return false
+	}
+	p := (*_Hchan)(ch)
+	return p.closed
+}
+
+// mapSize returns the bytes of the header and the bucket arrays of the map
+// of type t, overflow buckets are not included.
+func mapSize(t *MapType, m IWord) uintptr {
+	if m == nil {
+		return 0
+	}
+	n := unsafe.Sizeof(_Hmap{})
+	if t.HMap != nil {
+		n = t.HMap.Size
+	}
+	h := (*_Hmap)(m)
+	n += uintptr(1) << h.B * uintptr(h.bucketsize)
+	if h.oldbuckets != nil && h.B > 0 {
+		// Growing, the old buckets are half the size.
+		n += uintptr(1) << (h.B - 1) * uintptr(h.bucketsize)
+	}
+	return n
+}
+
+// chanSize returns the bytes of the header and the buffer of the channel,
+// they are allocated together.
+func chanSize(ch IWord) uintptr {
+	if ch == nil {
+		return 0
+	}
+	p := (*_Hchan)(ch)
+	return unsafe.Sizeof(_Hchan{}) + uintptr(p.dataqsiz)*uintptr(p.elemsize)
+}
@@ -5,11 +5,18 @@
 package surface
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/achun/testing-want"
 	"go/ast"
+	"math"
 	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 )
 
 func TestType(t *testing.T) {
@@ -420,3 +427,955 @@ func TestMap(t *testing.T) {
 		wt.Equal(rv.Interface(), sv.Interface())
 	}
 }
+
+func TestMethodValue(t *testing.T) {
+	wt := want.T(t)
+	ai := &ast.Ident{Name: "surface"}
+	for _, s := range []interface{}{*ai, ai, fmt.Stringer(ai)} {
+		sv := ValueOf(s)
+		rv := reflect.ValueOf(s)
+		wt.Equal(rv.NumMethod(), sv.NumMethod())
+		sm := sv.MethodByName("String")
+		rm := rv.MethodByName("String")
+		wt.Equal(rm.IsValid(), sm.IsValid())
+		if !rm.IsValid() {
+			continue
+		}
+		wt.True(sm.IsMethod())
+		wt.Equal(rm.Type().String(), sm.Type.String())
+		wt.Equal(rm.CanInterface(), sm.CanInterface())
+	}
+	wt.True(!ValueOf(ai).MethodByName("exprNode").IsValid())
+}
+
+func TestFuncCall(t *testing.T) {
+	wt := want.T(t)
+
+	out := ValueOf(fmt.Sprint).Func().Call([]Value{ValueOf("a"), ValueOf(1)})
+	wt.Equal(1, len(out))
+	wt.Equal("a1", out[0].String())
+
+	out = ValueOf(fmt.Sprint).Func().CallSlice([]Value{ValueOf([]interface{}{"a", 1})})
+	wt.Equal("a1", out[0].String())
+
+	out = ValueOf(&ast.Ident{Name: "surface"}).MethodByName("String").Call(nil)
+	wt.Equal("surface", out[0].String())
+
+	var got interface{}
+	ident := &ast.Ident{}
+	var any interface{} = "any"
+	for _, fast := range []struct {
+		fn   interface{}
+		args []Value
+		want interface{}
+	}{
+		{func() { got = "none" }, nil, "none"},
+		{func(s string) { got = s }, []Value{ValueOf("fast")}, "fast"},
+		{func(i int) { got = i }, []Value{ValueOf(7)}, 7},
+		{func(b bool) { got = b }, []Value{ValueOf(true)}, true},
+		{func(i interface{}) { got = i }, []Value{ValueOf(&any).Ptr().Elem()}, "any"},
+		{func(i interface{}) { got = i }, []Value{ValueOf(ident)}, ident},
+		{func(i interface{}) { got = i }, []Value{ValueOf(uintptr(9))}, uintptr(9)},
+	} {
+		fn := ValueOf(fast.fn).Func()
+		allocs := testing.AllocsPerRun(10, func() {
+			fn.Call(fast.args)
+		})
+		wt.Equal(fast.want, got)
+		wt.Equal(float64(0), allocs, fast.fn)
+	}
+}
+
+func TestSliceView(t *testing.T) {
+	wt := want.T(t)
+
+	buf := make([]int, 4, 8)
+	sv := ValueOf(buf).Slice()
+	a := sv.Slice(0, 2)
+	b := sv.Slice(2, 4)
+	c := sv.Slice3(2, 4, 4)
+	wt.Equal(2, a.Len())
+	wt.Equal(8, a.Cap())
+	wt.Equal(2, c.Cap())
+	wt.True(!Overlaps(a, b))
+	wt.True(SharesBacking(a, b))
+	wt.True(Overlaps(sv, b))
+	wt.True(!SharesBacking(sv.Slice3(0, 2, 2), c))
+	wt.True(!SharesBacking(sv, ValueOf(make([]int, 4)).Slice()))
+
+	arr := [4]int{1, 2, 3, 4}
+	as := ValueOf(&arr).Ptr().Elem().Array().Slice(1, 3)
+	wt.Equal(2, as.Len())
+	wt.Equal(3, as.Cap())
+	wt.Equal(2, as.Index(0).Int())
+}
+
+func TestSetter(t *testing.T) {
+	wt := want.T(t)
+
+	u := struct {
+		Name  string
+		Age   uint8
+		Score float32
+		Tags  []byte
+		Ok    bool
+		n     int
+	}{}
+	v := ValueOf(&u).Ptr().Elem().Struct()
+	v.Field(0).SetString("surface")
+	v.Field(1).SetUint64(300)
+	v.Field(2).SetFloat64(1.5)
+	v.Field(3).SetBytes([]byte("ab"))
+	v.Field(4).Set(ValueOf(true))
+	wt.Equal("surface", u.Name)
+	wt.Equal(uint8(44), u.Age)
+	wt.Equal(float32(1.5), u.Score)
+	wt.Equal("ab", string(u.Tags))
+	wt.True(u.Ok)
+	wt.True(!v.Field(5).CanSet())
+
+	v.Field(0).SetZero()
+	wt.Equal("", u.Name)
+
+	var s fmt.Stringer
+	ValueOf(&s).Ptr().Elem().Set(ValueOf(&ast.Ident{Name: "x"}))
+	wt.Equal("x", s.String())
+}
+
+func TestUnrestricted(t *testing.T) {
+	wt := want.T(t)
+
+	u := struct{ n int }{}
+	f := ValueOf(&u).Ptr().Elem().Struct().Field(0)
+	wt.True(!f.CanSet())
+	wt.True(!f.CanInterface())
+	f = Unrestricted(f)
+	wt.True(f.CanSet())
+	f.SetInt(3)
+	wt.Equal(3, f.Interface())
+	wt.Equal(3, u.n)
+}
+
+func TestMake(t *testing.T) {
+	wt := want.T(t)
+
+	p := New(TypeOf(ast.Ident{}))
+	wt.Equal("*ast.Ident", p.Type.String())
+	p.Elem().Struct().FieldByName("Name").SetString("x")
+	wt.Equal("x", p.Interface().(*ast.Ident).Name)
+
+	wt.Equal(ast.Ident{}, Zero(TypeOf(ast.Ident{})).Interface())
+	wt.Equal(0, Zero(TypeOf(0)).Int())
+
+	s := MakeSlice(TypeOf([]string{}), 2, 4)
+	wt.Equal(2, s.Len())
+	wt.Equal(4, s.Cap())
+
+	m := MakeMapWithSize(TypeOf(map[string]int{}), 8)
+	wt.Equal(0, m.Len())
+
+	c := MakeChan(TypeOf(make(chan int)), 3)
+	wt.Equal(3, c.Cap())
+}
+
+func TestAppend(t *testing.T) {
+	wt := want.T(t)
+
+	s := ValueOf([]*ast.Ident{}).Slice()
+	for i := 0; i < 10; i++ {
+		s = Append(s, ValueOf(&ast.Ident{Name: fmt.Sprint(i)}))
+	}
+	wt.Equal(10, s.Len())
+	wt.True(s.Cap() >= 10)
+	s = AppendSlice(s, s)
+	wt.Equal(20, s.Len())
+	wt.Equal("9", s.Interface().([]*ast.Ident)[19].Name)
+
+	dst := ValueOf(make([]*ast.Ident, 3)).Slice()
+	wt.Equal(3, Copy(dst, s))
+
+	buf := []int{1, 2, 3}
+	v := ValueOf(&buf).Ptr().Elem().Slice()
+	v.Grow(10)
+	wt.Equal(3, len(buf))
+	wt.True(cap(buf) >= 13)
+	v.SetLen(5)
+	wt.Equal(5, len(buf))
+	v.SetCap(5)
+	wt.Equal(5, cap(buf))
+
+	// Past a capacity of 1024 the growth is a quarter, whatever the length.
+	big := ValueOf(make([]byte, 10, 1024)).Slice()
+	big = AppendSlice(big, ValueOf(make([]byte, 1015)).Slice())
+	wt.Equal(1025, big.Len())
+	wt.Equal(1280, big.Cap())
+}
+
+func TestMapSetIndex(t *testing.T) {
+	wt := want.T(t)
+
+	m := map[string]interface{}{}
+	sv := ValueOf(m).Map()
+	sv.SetIndex(ValueOf("1"), ValueOf(1))
+	sv.SetIndex(ValueOf("2"), ValueOf("2"))
+	wt.Equal(2, len(m))
+	wt.Equal(1, m["1"])
+	sv.Delete(ValueOf("1"))
+	wt.Equal(1, sv.Len())
+	sv.Clear()
+	wt.Equal(0, len(m))
+
+	nan := map[float64]int{math.NaN(): 1, math.NaN(): 2, 1: 3}
+	nv := ValueOf(nan).Map()
+	nv.Clear()
+	wt.Equal(0, len(nan))
+	nan[2] = 4
+	wt.Equal(4, nan[2])
+	wt.Equal(1, len(nan))
+}
+
+func TestChanOps(t *testing.T) {
+	wt := want.T(t)
+
+	ch := make(chan string, 1)
+	sv := ValueOf(ch).Chan()
+	wt.True(sv.TrySend(ValueOf("a")))
+	wt.True(!sv.TrySend(ValueOf("b")))
+	x, ok := sv.Recv()
+	wt.True(ok)
+	wt.Equal("a", x.String())
+	_, ok = sv.TryRecv()
+	wt.True(!ok)
+
+	chosen, _, _ := Select([]SelectCase{
+		{Dir: SelectRecv, Chan: sv},
+		{Dir: SelectSend, Chan: sv, Send: ValueOf("c")},
+	})
+	wt.Equal(1, chosen)
+	chosen, x, ok = Select([]SelectCase{
+		{Dir: SelectRecv, Chan: sv},
+		{Dir: SelectDefault},
+	})
+	wt.Equal(0, chosen)
+	wt.Equal("c", x.String())
+
+	sv.Close()
+	_, ok = sv.Recv()
+	wt.True(!ok)
+}
+
+func TestTypedCopyGC(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+		Tags []string
+	}
+	wt := want.T(t)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+
+	dst := make([]node, 64)
+	for n := 0; n < 200; n++ {
+		for i := range dst {
+			src := node{fmt.Sprint(n, i), &node{Name: "next"}, []string{fmt.Sprint(i)}}
+			TypedCopy(ValueOf(&dst[i]).Ptr().Elem(), ValueOf(&src).Ptr().Elem())
+		}
+		runtime.GC()
+		for i := range dst {
+			wt.Equal(fmt.Sprint(n, i), dst[i].Name)
+			wt.Equal("next", dst[i].Next.Name)
+			wt.Equal(fmt.Sprint(i), dst[i].Tags[0])
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestConvert(t *testing.T) {
+	type name string
+	wt := want.T(t)
+
+	x, err := ValueOf(int64(300)).Convert(TypeOf(uint8(0)))
+	wt.True(err == nil)
+	wt.Equal(uint8(44), x.Uint8())
+
+	x, _ = ValueOf(1.75).Convert(TypeOf(0))
+	wt.Equal(1, x.Int())
+
+	x, _ = ValueOf("surface").Convert(TypeOf([]byte{}))
+	wt.Equal("surface", string(x.Slice().Bytes()))
+
+	x, _ = ValueOf([]rune("surface")).Convert(TypeOf(""))
+	wt.Equal("surface", x.String())
+
+	x, _ = ValueOf(name("surface")).Convert(TypeOf(""))
+	wt.Equal("surface", x.Interface())
+
+	x, _ = ValueOf([]int{1, 2, 3}).Convert(TypeOf(&[2]int{}))
+	wt.Equal([2]int{1, 2}, *x.Interface().(*[2]int))
+
+	_, err = ValueOf([]int{1}).Convert(TypeOf(&[2]int{}))
+	wt.True(err != nil)
+
+	_, err = ValueOf("surface").Convert(TypeOf(0))
+	wt.True(err != nil)
+}
+
+func TestReinterpret(t *testing.T) {
+	type point struct {
+		X, Y int
+		Tag  *string
+	}
+	type vec struct {
+		A, B int
+		Name *string
+	}
+	type bad struct {
+		A, B int
+		Name uintptr
+	}
+	wt := want.T(t)
+
+	wt.True(Compatible(TypeOf(point{}), TypeOf(vec{})) == nil)
+	err := Compatible(TypeOf(point{}), TypeOf(bad{}))
+	wt.True(err != nil)
+	wt.Equal(".Tag", err.(*LayoutError).Path)
+	err = Compatible(TypeOf([1]struct {
+		A *int
+		B int
+	}{}), TypeOf([1]struct {
+		A int
+		B *int
+	}{}))
+	wt.True(err != nil)
+	wt.Equal("[].A", err.(*LayoutError).Path)
+
+	p := point{X: 1, Y: 2}
+	v, err := Reinterpret(ValueOf(&p).Ptr().Elem(), TypeOf(vec{}))
+	wt.True(err == nil)
+	v.Struct().Field(1).SetInt(3)
+	wt.Equal(3, p.Y)
+}
+
+func TestOverlay(t *testing.T) {
+	type record struct {
+		ID    uint32
+		Score uint16
+		Flag  uint8
+	}
+	wt := want.T(t)
+
+	buf := make([]uint64, 2)
+	b := (*[16]byte)(unsafe.Pointer(&buf[0]))[:]
+	b[0], b[4], b[6] = 7, 9, 1
+	v, err := Overlay(b, TypeOf(record{}))
+	wt.True(err == nil)
+	wt.True(v.CanAddr())
+	wt.True(!v.CanSet())
+	wt.Equal(uint32(7), v.Struct().Field(0).Uint32())
+	wt.Equal(uint16(9), v.Struct().Field(1).Uint16())
+
+	_, err = Overlay(b[:4], TypeOf(record{}))
+	wt.True(err != nil)
+	_, err = Overlay(b[1:], TypeOf(record{}))
+	wt.True(err != nil)
+	_, err = Overlay(b, TypeOf(&record{}))
+	wt.True(err != nil)
+
+	for _, b := range [][]byte{b, nil} {
+		v, err = Overlay(b, TypeOf(struct{}{}))
+		wt.True(err == nil)
+		wt.True(v.CanAddr())
+		wt.True(!v.CanSet())
+	}
+
+	panics := func(f func()) (ok bool) {
+		defer func() { ok = recover() != nil }()
+		f()
+		return
+	}
+	wt.True(panics(func() { ValueAt(unsafe.Pointer(&b[1]), TypeOf(record{})) }))
+	wt.True(panics(func() { ValueAt(unsafe.Pointer(&b[0]), TypeOf(&record{})) }))
+
+	r := record{ID: 3}
+	v = NewAt(TypeOf(r), unsafe.Pointer(&r)).Elem()
+	wt.True(v.CanSet())
+	wt.Equal(uint32(3), v.Struct().Field(0).Uint32())
+}
+
+func TestDeepEqual(t *testing.T) {
+	type user struct {
+		Name  string
+		Score float64
+		Tags  []string
+		Attrs map[string]int
+		Next  *user
+		pass  string
+	}
+	wt := want.T(t)
+
+	a := &user{Name: "a", Score: 1, pass: "x"}
+	b := &user{Name: "a", Score: 1.0001, Tags: []string{}, pass: "y"}
+	a.Next, b.Next = a, b
+
+	wt.True(!DeepEqual(ValueOf(a), ValueOf(b)))
+	wt.True(DeepEqual(ValueOf(a), ValueOf(b),
+		FloatTolerance(0.01),
+		NilEqualsEmpty(),
+		IgnoreFields("pass"),
+	))
+	wt.True(!DeepEqual(ValueOf(a), ValueOf(b),
+		FloatTolerance(0.01),
+		NilEqualsEmpty(),
+	))
+	wt.True(DeepEqual(ValueOf(a), ValueOf(b),
+		Comparer(TypeOf(user{}), func(x, y Value) bool {
+			return x.Struct().Field(0).String() == y.Struct().Field(0).String()
+		}),
+	))
+
+	x := []user{{Attrs: map[string]int{"k": 1}}}
+	y := []user{{Attrs: map[string]int{"k": 2}}}
+	wt.True(!DeepEqual(ValueOf(x), ValueOf(y)))
+	wt.True(DeepEqual(ValueOf(x), ValueOf(y), IgnoreFields(`[*].Attrs["k"]`)))
+
+	type secret struct{ Secret string }
+	type pair struct{ A, B *secret }
+	s1, s2 := &secret{"x"}, &secret{"y"}
+	wt.True(DeepEqual(ValueOf(pair{s1, s1}), ValueOf(pair{s2, s1}), IgnoreFields("A.Secret")))
+	wt.True(!DeepEqual(ValueOf(pair{s1, s1}), ValueOf(pair{s2, s2}), IgnoreFields("A.Secret")))
+}
+
+func TestDiff(t *testing.T) {
+	type profile struct {
+		Name string
+	}
+	type user struct {
+		Profile *profile
+		Tags    []string
+	}
+	type state struct {
+		Users []user
+		Cache map[string]interface{}
+	}
+	wt := want.T(t)
+
+	a := state{
+		Users: []user{{&profile{"a"}, []string{"x", "y"}}},
+		Cache: map[string]interface{}{"k": 1, "gone": true},
+	}
+	b := state{
+		Users: []user{{&profile{"b"}, []string{"x"}}},
+		Cache: map[string]interface{}{"k": "1", "new": 2},
+	}
+	changes := Diff(ValueOf(a), ValueOf(b))
+	wt.Equal(5, len(changes))
+
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.Path + " " + c.Kind.String()
+	}
+	wt.Equal([]string{
+		"Users[0].Profile.Name modified",
+		"Users[0].Tags[1] removed",
+		`Cache["gone"] removed`,
+		`Cache["k"] type-changed`,
+		`Cache["new"] added`,
+	}, got)
+	wt.Equal(`Users[0].Profile.Name: modified "a" -> "b"`, changes[0].String())
+	wt.Equal("Tags: added [x y]", Change{Path: "Tags", Kind: Added, New: ValueOf([]string{"x", "y"})}.String())
+	wt.Equal("["+strings.Repeat("x", 63)+"...", formatKey(ValueOf([]string{strings.Repeat("x", 80)})))
+
+	ma := map[string]interface{}{"n": 1}
+	ma["self"] = ma
+	mb := map[string]interface{}{"n": 2}
+	mb["self"] = mb
+	sa := []interface{}{1, nil}
+	sa[1] = sa
+	sb := []interface{}{2, nil}
+	sb[1] = sb
+	got = nil
+	for _, c := range Diff(ValueOf([]interface{}{ma, sa}), ValueOf([]interface{}{mb, sb})) {
+		got = append(got, c.Path+" "+c.Kind.String())
+	}
+	wt.Equal([]string{
+		`[0]["n"] modified`,
+		"[1][0] modified",
+	}, got)
+}
+
+func TestClone(t *testing.T) {
+	type node struct {
+		Name  string
+		Next  *node
+		Peer  *node
+		Tags  []string
+		Attrs map[string]*node
+		Ch    chan int
+		n     int
+	}
+	wt := want.T(t)
+
+	a := &node{Name: "a", Tags: []string{"x"}, Ch: make(chan int, 2), n: 1}
+	b := &node{Name: "b", Next: a, Peer: a}
+	a.Next = b
+	a.Attrs = map[string]*node{"b": b}
+
+	c := Clone(ValueOf(a)).Interface().(*node)
+	wt.True(c != a)
+	wt.True(DeepEqual(ValueOf(a), ValueOf(c), Comparer(TypeOf(a.Ch), func(x, y Value) bool {
+		return x.Chan().Cap() == y.Chan().Cap()
+	})))
+	wt.True(c.Next.Next == c)
+	wt.True(c.Next.Peer == c)
+	wt.True(c.Attrs["b"] == c.Next)
+	wt.True(c.Ch != a.Ch)
+	wt.Equal(2, cap(c.Ch))
+	wt.Equal(1, c.n)
+
+	c.Tags[0] = "y"
+	wt.Equal("x", a.Tags[0])
+
+	c = Clone(ValueOf(a), ShareChans()).Interface().(*node)
+	wt.True(c.Ch == a.Ch)
+
+	nan := map[float64]int{math.NaN(): 1, math.NaN(): 2, 1: 3}
+	cm := Clone(ValueOf(nan)).Interface().(map[float64]int)
+	wt.Equal(3, len(cm))
+	sum := 0
+	for _, n := range cm {
+		sum += n
+	}
+	wt.Equal(6, sum)
+
+	ro := Clone(ValueOf(*a).Struct().Field(6))
+	wt.True(!ro.CanSet())
+	wt.True(!ro.CanInterface())
+	wt.Equal(1, ro.Int())
+}
+
+type testVisitor struct {
+	enter, leave []string
+	skip         string
+}
+
+func (v *testVisitor) Enter(n *Node) error {
+	v.enter = append(v.enter, n.Path)
+	if n.Revisit {
+		v.enter[len(v.enter)-1] += " revisit"
+	}
+	if v.skip != "" && n.Path == v.skip {
+		return SkipChildren
+	}
+	return nil
+}
+
+func (v *testVisitor) Leave(n *Node) error {
+	v.leave = append(v.leave, n.Path)
+	return nil
+}
+
+func TestWalk(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+		kids []int
+	}
+	wt := want.T(t)
+
+	a := &node{Name: "a", kids: []int{1}}
+	a.Next = a
+
+	vis := &testVisitor{}
+	wt.True(Walk(ValueOf(a), vis) == nil)
+	wt.Equal([]string{"", "", "Name", "Next revisit", "kids", "kids[0]"}, vis.enter)
+	wt.Equal(len(vis.enter), len(vis.leave))
+
+	vis = &testVisitor{skip: "kids"}
+	wt.True(Walker{MaxDepth: 1}.Walk(ValueOf(a), vis) == nil)
+	wt.Equal([]string{"", ""}, vis.enter)
+
+	// The pointer is reached first at MaxDepth, then through a shallower path.
+	type deep struct{ P *int }
+	type root struct {
+		D deep
+		P *int
+	}
+	x := 1
+	vis = &testVisitor{}
+	wt.True(Walker{MaxDepth: 2}.Walk(ValueOf(root{deep{&x}, &x}), vis) == nil)
+	wt.Equal([]string{"", "D", "D.P", "P", "P"}, vis.enter)
+}
+
+func TestDump(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+		Buf  []byte
+		Ch   chan int
+		M    map[string]int
+		any  interface{}
+	}
+	wt := want.T(t)
+
+	a := &node{
+		Name: "a",
+		Buf:  []byte("abc"),
+		Ch:   make(chan int, 2),
+		M:    map[string]int{"y": 2, "x": 1},
+		any:  3,
+	}
+	a.Next = a
+
+	var buf bytes.Buffer
+	wt.True(Dump(&buf, ValueOf(a), DumpOptions{MaxWidth: 1}) == nil)
+	addr := fmt.Sprintf("0x%x", uintptr(unsafe.Pointer(a)))
+	wt.Equal("(*surface.node) "+addr+" {\n"+
+		"  Name: (string) (len=1) \"a\"\n"+
+		"  Next: (*surface.node) "+addr+" <already shown>\n"+
+		"  Buf: ([]uint8) (len=3 cap=3) {\n"+
+		"    00000000  61                                                |a|\n"+
+		"    ... (2 more)\n"+
+		"  }\n"+
+		"  Ch: (chan int) (len=0 cap=2)\n"+
+		"  M: (map[string]int) (len=2) {\n"+
+		"    \"x\": (int) 1\n"+
+		"    ... (1 more)\n"+
+		"  }\n"+
+		"  any: (interface {}) (int) 3\n"+
+		"}\n", buf.String())
+}
+
+type litNode struct {
+	Name string
+	Next *litNode
+	Any  interface{}
+	Tags []string
+}
+
+func TestGoLiteral(t *testing.T) {
+	wt := want.T(t)
+	here := InPackage("github.com/ZxxLang/surface")
+
+	s, err := GoLiteral(ValueOf(ast.Ident{Name: "x", NamePos: 12}))
+	wt.True(err == nil)
+	wt.Equal("ast.Ident{\n\tNamePos: 12,\n\tName:    \"x\",\n}", s)
+
+	s, _ = GoLiteral(ValueOf(litNode{Any: uint8(1), Tags: []string{"a"}}), here)
+	wt.Equal("litNode{\n\tAny: uint8(1),\n\tTags: []string{\n\t\t\"a\",\n\t},\n}", s)
+
+	a := &litNode{Name: "a"}
+	a.Next = a
+	s, _ = GoLiteral(ValueOf(a))
+	wt.Equal("func() *surface.litNode {\n\tp1 := new(surface.litNode)\n\t*p1 = surface.litNode{\n\t\tName: \"a\",\n\t\tNext: p1,\n\t}\n\treturn p1\n}()", s)
+
+	_, err = GoLiteral(ValueOf(fmt.Sprint))
+	wt.True(err != nil)
+	_, err = GoLiteral(ValueOf(map[float64]int{math.NaN(): 1}))
+	wt.True(err != nil)
+}
+
+func TestFootprint(t *testing.T) {
+	type entry struct {
+		Key  string
+		Data []byte
+	}
+	wt := want.T(t)
+
+	e := &entry{Key: "key", Data: make([]byte, 100, 128)}
+	cache := []*entry{e, e}
+	r := Footprint(ValueOf(cache))
+
+	size := unsafe.Sizeof(cache) +
+		2*unsafe.Sizeof(e) + // backing array of cache
+		unsafe.Sizeof(*e) + // e counted once
+		3 + // e.Key
+		128 // e.Data
+	wt.Equal(size, r.Total)
+	wt.Equal(TypeOf([]byte{}), r.ByType[0].Type)
+	wt.Equal(uintptr(128), r.ByType[0].Bytes)
+
+	// Subslices and interior pointers share the bytes of their block.
+	type pair struct {
+		X, Y int
+	}
+	type views struct {
+		A, B []byte
+		S    *pair
+		Y    *int
+	}
+	b := make([]byte, 100)
+	p := &pair{}
+	vs := views{b[:50], b[10:], p, &p.Y}
+	r = Footprint(ValueOf(vs))
+	wt.Equal(unsafe.Sizeof(vs)+100+unsafe.Sizeof(*p), r.Total)
+}
+
+func TestGraph(t *testing.T) {
+	type item struct {
+		Name string
+		Next *item
+	}
+	type index struct {
+		Items []*item
+		ByKey map[string]*item
+	}
+	wt := want.T(t)
+
+	a := &item{Name: "a"}
+	b := &item{Name: "b", Next: a}
+	g := Graph(ValueOf(index{
+		Items: []*item{a, b},
+		ByKey: map[string]*item{"a": a},
+	}))
+
+	// root, Items backing array, a, b, ByKey
+	wt.Equal(5, len(g.Nodes))
+	labels := make([]string, len(g.Edges))
+	for i, e := range g.Edges {
+		labels[i] = strconv.Itoa(e.From) + "->" + strconv.Itoa(e.To) + " " + e.Label
+	}
+	wt.Equal([]string{
+		"0->1 Items",
+		"1->2 [0]",
+		"1->3 [1]",
+		"3->2 Next",
+		"0->4 ByKey",
+		`4->2 ["a"]`,
+	}, labels)
+
+	var buf bytes.Buffer
+	wt.True(g.WriteDOT(&buf) == nil)
+	wt.True(strings.Contains(buf.String(), `n4 -> n2 [label="[\"a\"]"];`))
+
+	js, err := g.MarshalJSON()
+	wt.True(err == nil)
+	wt.True(bytes.Contains(js, []byte(`{"from":0,"to":1,"label":"Items"}`)))
+}
+
+func TestQuery(t *testing.T) {
+	type item struct{ SKU string }
+	type order struct {
+		Items []*item
+		note  interface{}
+	}
+	type shop struct {
+		Orders []order
+		ByID   map[int64]*order
+		Tags   map[string]int
+	}
+	wt := want.T(t)
+
+	o := order{Items: []*item{{"a"}, {"b"}}, note: &item{"n"}}
+	v := ValueOf(&shop{
+		Orders: []order{{}, o},
+		ByID:   map[int64]*order{7: &o},
+		Tags:   map[string]int{"x": 1, "y": 2},
+	})
+
+	paths := func(expr string) []string {
+		ms, err := Query(v, expr)
+		wt.True(err == nil, err)
+		var ps []string
+		for _, m := range ms {
+			ps = append(ps, m.Path+"="+fmt.Sprint(Unrestricted(m.Value).Interface()))
+		}
+		return ps
+	}
+	wt.Equal([]string{"Orders[1].Items[0].SKU=a", "Orders[1].Items[1].SKU=b"},
+		paths("Orders[*].Items[*].SKU"))
+	wt.Equal([]string{"ByID[7].Items[1].SKU=b"}, paths("ByID[7].Items[1].SKU"))
+	wt.Equal([]string{`Tags["y"]=2`}, paths(`Tags["y"]`))
+	wt.Equal([]string{"Orders[1].note.SKU=n"}, paths("Orders[1].note.SKU"))
+	wt.Equal([]string(nil), paths("Orders[5].Items"))
+	wt.Equal([]string(nil), paths(`ByID["7"]`))
+
+	_, err := Query(v, "Orders[x]")
+	wt.NotNil(err)
+	_, err = Query(v, "Orders[1")
+	wt.NotNil(err)
+}
+
+type accessorRow struct {
+	Header struct {
+		ID   int32
+		Name string
+	}
+	Items []accessorItem
+	Next  *accessorRow
+}
+
+type accessorItem struct {
+	SKU   string
+	Price [2]int64
+}
+
+func TestAccessor(t *testing.T) {
+	wt := want.T(t)
+	typ := TypeOf(accessorRow{})
+
+	row := &accessorRow{Items: []accessorItem{{"a", [2]int64{1, 2}}}}
+	row.Header.ID = 7
+	row.Next = &accessorRow{}
+	row.Next.Header.Name = "next"
+
+	a, err := CompileAccessor(typ, "Header.ID")
+	wt.True(err == nil, err)
+	wt.Equal(int64(7), a.GetInt64(unsafe.Pointer(row)))
+
+	a, err = CompileAccessor(typ, "Items[0].Price[1]")
+	wt.True(err == nil, err)
+	wt.Equal(int64(2), a.GetInt64(unsafe.Pointer(row)))
+	v := a.Get(unsafe.Pointer(row))
+	v.SetInt64(3)
+	wt.Equal(int64(3), row.Items[0].Price[1])
+
+	a, err = CompileAccessor(typ, "Items[1].SKU")
+	wt.True(err == nil, err)
+	wt.Equal("", a.GetString(unsafe.Pointer(row)))
+	wt.True(!a.Get(unsafe.Pointer(row)).IsValid())
+
+	a, err = CompileAccessor(typ, "Next.Header.Name")
+	wt.True(err == nil, err)
+	wt.Equal("next", a.GetString(unsafe.Pointer(row)))
+	wt.Equal("", a.GetString(unsafe.Pointer(row.Next)))
+
+	for _, path := range []string{"Header.Missing", "Items[0].Price[2]", "Items[*]", "Header[0]"} {
+		_, err = CompileAccessor(typ, path)
+		wt.NotNil(err, path)
+	}
+}
+
+// benchString keeps benchmarked results alive.
+var benchString string
+
+func BenchmarkAccessorGetInt64(b *testing.B) {
+	row := &accessorRow{Items: []accessorItem{{"a", [2]int64{1, 2}}}}
+	a, _ := CompileAccessor(TypeOf(accessorRow{}), "Items[0].Price[1]")
+	p := unsafe.Pointer(row)
+	for i := 0; i < b.N; i++ {
+		a.GetInt64(p)
+	}
+}
+
+func BenchmarkAccessorGetString(b *testing.B) {
+	row := &accessorRow{Items: []accessorItem{{"a", [2]int64{1, 2}}}}
+	a, _ := CompileAccessor(TypeOf(accessorRow{}), "Items[0].SKU")
+	p := unsafe.Pointer(row)
+	for i := 0; i < b.N; i++ {
+		benchString = a.GetString(p)
+	}
+}
+
+func BenchmarkReflectFieldByNameInt64(b *testing.B) {
+	row := &accessorRow{Items: []accessorItem{{"a", [2]int64{1, 2}}}}
+	for i := 0; i < b.N; i++ {
+		reflect.ValueOf(row).Elem().FieldByName("Items").Index(0).FieldByName("Price").Index(1).Int()
+	}
+}
+
+func BenchmarkReflectFieldByIndexString(b *testing.B) {
+	row := &accessorRow{Items: []accessorItem{{"a", [2]int64{1, 2}}}}
+	for i := 0; i < b.N; i++ {
+		benchString = reflect.ValueOf(row).Elem().Field(1).Index(0).Field(0).String()
+	}
+}
+
+type infoBase struct {
+	ID   int `json:"id,omitempty" db:"id"`
+	Name string
+}
+
+type infoNamed struct {
+	Name string
+}
+
+type infoUser struct {
+	infoBase
+	*infoNamed
+	Tags  []string
+	email string
+}
+
+func (infoUser) String() string { return "" }
+
+func TestInfoOf(t *testing.T) {
+	wt := want.T(t)
+	typ := TypeOf(infoUser{})
+	ti := InfoOf(typ)
+	wt.True(ti == InfoOf(typ))
+
+	var names []string
+	for _, f := range ti.Fields {
+		s := f.Name + fmt.Sprint(f.Index)
+		if f.Shadowed {
+			s += " shadowed"
+		}
+		if f.Indirect {
+			s += " indirect"
+		}
+		names = append(names, s)
+	}
+	wt.Equal([]string{
+		"infoBase[0]",
+		"ID[0 0]",
+		"Name[0 1] shadowed",
+		"infoNamed[1]",
+		"Name[1 0] shadowed indirect",
+		"Tags[2]",
+		"email[3]",
+	}, names)
+
+	id, ok := ti.FieldByName("ID")
+	wt.True(ok)
+	wt.Equal(unsafe.Offsetof(infoUser{}.ID), id.Offset)
+	wt.Equal("id", id.Tags["json"].Name)
+	wt.True(id.Tags["json"].Has("omitempty"))
+	wt.Equal("id", id.Tags["db"].Name)
+	_, ok = ti.FieldByName("Name")
+	wt.True(!ok)
+
+	wt.Equal([]uintptr{
+		unsafe.Offsetof(infoUser{}.infoBase) + unsafe.Offsetof(infoBase{}.Name),
+		unsafe.Offsetof(infoUser{}.infoNamed),
+		unsafe.Offsetof(infoUser{}.Tags),
+		unsafe.Offsetof(infoUser{}.email),
+	}, ti.Pointers)
+	wt.True(!ti.Comparable)
+	wt.True(!ti.ZeroSize)
+	wt.Equal([]string{"String"}, ti.Methods)
+
+	wt.True(InfoOf(TypeOf(struct{}{})).ZeroSize)
+	wt.True(InfoOf(TypeOf(infoBase{})).Comparable)
+}
+
+func TestInfoOfConcurrent(t *testing.T) {
+	wt := want.T(t)
+	types := []*Type{TypeOf(0), TypeOf(""), TypeOf(infoBase{}), TypeOf(&infoUser{})}
+	infos := make([]*TypeInfo, 8*len(types))
+	var wg sync.WaitGroup
+	for i := range infos {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			infos[i] = InfoOf(types[i%len(types)])
+		}(i)
+	}
+	wg.Wait()
+	for i, ti := range infos {
+		wt.True(ti == InfoOf(types[i%len(types)]))
+	}
+}
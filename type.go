@@ -98,6 +98,11 @@ type ArrayType struct {
 	len   uintptr
 }
 
+// Len returns the length of the array type t.
+func (t *ArrayType) Len() int {
+	return int(t.len)
+}
+
 // chanType represents a channel type.
 type ChanType struct {
 	Type `reflect:"chan"`
@@ -105,6 +110,11 @@ type ChanType struct {
 	Dir  uintptr // channel direction (ChanDir)
 }
 
+// Direction returns the channel direction of t.
+func (t *ChanType) Direction() ChanDir {
+	return ChanDir(t.Dir)
+}
+
 // funcType represents a function type.
 type FuncType struct {
 	Type      `reflect:"func"`
@@ -211,50 +221,58 @@ type StructTag string
 // returned by Get is unspecified.
 func (tag StructTag) Get(key string) string {
 	for tag != "" {
-		// skip leading space
-		i := 0
-		for i < len(tag) && tag[i] == ' ' {
-			i++
-		}
-		tag = tag[i:]
-		if tag == "" {
-			break
-		}
-
-		// scan to colon.
-		// a space or a quote is a syntax error
-		i = 0
-		for i < len(tag) && tag[i] != ' ' && tag[i] != ':' && tag[i] != '"' {
-			i++
-		}
-		if i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+		name, value, rest, ok := tag.next()
+		if !ok {
 			break
 		}
-		name := string(tag[:i])
-		tag = tag[i+1:]
-
-		// scan quoted string to find value
-		i = 1
-		for i < len(tag) && tag[i] != '"' {
-			if tag[i] == '\\' {
-				i++
-			}
-			i++
-		}
-		if i >= len(tag) {
-			break
-		}
-		qvalue := string(tag[:i+1])
-		tag = tag[i+1:]
-
 		if key == name {
-			value, _ := strconv.Unquote(qvalue)
 			return value
 		}
+		tag = rest
 	}
 	return ""
 }
 
+// next returns the first key:"value" pair of the tag and the rest of it.
+// ok is false if there is no pair or the tag does not have the conventional format.
+func (tag StructTag) next() (key, value string, rest StructTag, ok bool) {
+	// skip leading space
+	i := 0
+	for i < len(tag) && tag[i] == ' ' {
+		i++
+	}
+	tag = tag[i:]
+	if tag == "" {
+		return
+	}
+
+	// scan to colon.
+	// a space or a quote is a syntax error
+	i = 0
+	for i < len(tag) && tag[i] != ' ' && tag[i] != ':' && tag[i] != '"' {
+		i++
+	}
+	if i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+		return
+	}
+	key = string(tag[:i])
+	tag = tag[i+1:]
+
+	// scan quoted string to find value
+	i = 1
+	for i < len(tag) && tag[i] != '"' {
+		if tag[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(tag) {
+		return
+	}
+	value, _ = strconv.Unquote(string(tag[:i+1]))
+	return key, value, tag[i+1:], true
+}
+
 func (t *Type) IsNil() bool {
 	return t == nil
 }
@@ -334,6 +352,9 @@ func (u IMethod) Exported() bool {
 
 func (u StructField) Name() string {
 	if u.name == nil { // Embedded
+		if u.Type.Kind() == KPtr {
+			return u.Type.Ptr().Elem.Name()
+		}
 		return u.Type.Name()
 	}
 	return *u.name
@@ -362,6 +383,11 @@ func (u StructField) HasTag() bool {
 	return u.tag != nil
 }
 
+// Offset returns the byte offset of the field within its struct.
+func (u StructField) Offset() uintptr {
+	return u.offset
+}
+
 func TypeOf(i interface{}) *Type {
 	ei := *(*EmptyInterface)(unsafe.Pointer(&i))
 	return ei.Type
@@ -380,6 +406,12 @@ func (t *Type) mustBe(expected Kind) {
 	}
 }
 
+// HasPointers reports whether values of type t contain pointers
+// the garbage collector has to scan.
+func (t *Type) HasPointers() bool {
+	return Kind(t.kind)&kindNoPointers == 0
+}
+
 // t.Kind must be KArray
 func (t *Type) Array() *ArrayType {
 	t.mustBe(KArray)
@@ -0,0 +1,289 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// TypeInfo is structural metadata of a type computed once by InfoOf.
+// It is shared by all callers and must not be modified.
+type TypeInfo struct {
+	Type *Type
+
+	// Fields of a struct type, including the fields promoted from
+	// embedded structs and pointers to structs, in declaration order
+	// depth-first: an embedded field is followed by its promoted fields.
+	Fields []FieldInfo
+
+	Pointers   []uintptr // offsets of the words holding pointers, in increasing order
+	Comparable bool      // values can be compared with ==
+	ZeroSize   bool      // values occupy no memory
+	Methods    []string  // names of the method set of the type, sorted
+}
+
+// FieldInfo describes a field of a TypeInfo.
+type FieldInfo struct {
+	Name  string
+	Type  *Type
+	Index []int // field indexes from the outer struct, as reflect.StructField.Index
+	Depth int   // embedding depth, 0 for fields declared in the outer struct
+
+	// Offset is the byte offset from the start of the outer struct.
+	// If Indirect, the path goes through embedded pointers and Offset
+	// is relative to the struct reached through the last of them.
+	Offset   uintptr
+	Indirect bool
+
+	Exported bool
+	Embedded bool
+
+	// Shadowed is true if the field cannot be selected by its name:
+	// a field of the same name is less deep, or as deep and ambiguous.
+	Shadowed bool
+
+	Tag  StructTag
+	Tags map[string]TagValue // parsed Tag, nil if the field has no tag
+}
+
+// A TagValue is a value of a struct tag split at commas,
+// as in `json:"name,omitempty"`.
+type TagValue struct {
+	Name    string
+	Options []string
+}
+
+// Has reports whether the option is in v.Options.
+func (v TagValue) Has(option string) bool {
+	for _, o := range v.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldByName returns the field selected by name, as a Go selector would.
+func (ti *TypeInfo) FieldByName(name string) (FieldInfo, bool) {
+	for _, f := range ti.Fields {
+		if f.Name == name && !f.Shadowed {
+			return f, true
+		}
+	}
+	return FieldInfo{}, false
+}
+
+// typeInfos is a copy-on-write map[*Type]*TypeInfo,
+// read without locking, replaced while holding mu.
+var typeInfos struct {
+	mu sync.Mutex
+	m  unsafe.Pointer // *map[*Type]*TypeInfo
+}
+
+// InfoOf returns the TypeInfo of t. The result is computed on the first
+// call for t and cached, later calls do not lock.
+// InfoOf is safe for concurrent use.
+func InfoOf(t *Type) *TypeInfo {
+	if p := atomic.LoadPointer(&typeInfos.m); p != nil {
+		if ti := (*(*map[*Type]*TypeInfo)(p))[t]; ti != nil {
+			return ti
+		}
+	}
+
+	typeInfos.mu.Lock()
+	defer typeInfos.mu.Unlock()
+	var m map[*Type]*TypeInfo
+	if p := atomic.LoadPointer(&typeInfos.m); p != nil {
+		m = *(*map[*Type]*TypeInfo)(p)
+		if ti := m[t]; ti != nil {
+			return ti
+		}
+	}
+	ti := newTypeInfo(t)
+	nm := make(map[*Type]*TypeInfo, len(m)+1)
+	for k, v := range m {
+		nm[k] = v
+	}
+	nm[t] = ti
+	atomic.StorePointer(&typeInfos.m, unsafe.Pointer(&nm))
+	return ti
+}
+
+func newTypeInfo(t *Type) *TypeInfo {
+	ti := &TypeInfo{
+		Type:       t,
+		Pointers:   pointerOffsets(t, 0, nil),
+		Comparable: comparable(t),
+		ZeroSize:   t.Size == 0,
+	}
+	if t.Kind() == KStruct {
+		ti.Fields = structFields(t)
+	}
+	if t.Kind() == KInterface {
+		for _, m := range t.Surface().Methods {
+			ti.Methods = append(ti.Methods, m.Name())
+		}
+	} else if t.uncommonType != nil {
+		for _, m := range t.uncommonType.Methods {
+			ti.Methods = append(ti.Methods, m.Name())
+		}
+	}
+	sort.Strings(ti.Methods)
+	return ti
+}
+
+// comparable reports whether values of type t can be compared with ==.
+func comparable(t *Type) bool {
+	switch t.Kind() {
+	case KFunc, KMap, KSlice:
+		return false
+	case KArray:
+		return comparable(t.Array().Elem)
+	case KStruct:
+		for _, f := range t.Struct().Fields {
+			if !comparable(f.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pointerOffsets appends the offsets of the pointer words of a value
+// of type t at offset base to out.
+func pointerOffsets(t *Type, base uintptr, out []uintptr) []uintptr {
+	if !t.HasPointers() {
+		return out
+	}
+	switch t.Kind() {
+	case KChan, KFunc, KMap, KPtr, KSlice, KString, KUnsafePointer:
+		out = append(out, base)
+	case KInterface:
+		out = append(out, base+ptrSize)
+	case KArray:
+		at := t.Array()
+		for i := 0; i < at.Len(); i++ {
+			out = pointerOffsets(at.Elem, base+uintptr(i)*at.Elem.Size, out)
+		}
+	case KStruct:
+		for _, f := range t.Struct().Fields {
+			out = pointerOffsets(f.Type, base+f.offset, out)
+		}
+	}
+	return out
+}
+
+// fieldScan is an embedded struct whose fields are to be listed.
+type fieldScan struct {
+	typ      *Type
+	index    []int
+	offset   uintptr
+	indirect bool
+}
+
+// structFields returns the fields of the struct type t and the fields
+// promoted from embedded structs, breadth first as Go resolves selectors.
+func structFields(t *Type) []FieldInfo {
+	var fields []FieldInfo
+	current := []fieldScan{{typ: t}}
+	seen := map[*Type]bool{} // types expanded at a lesser depth
+	for len(current) != 0 {
+		var next []fieldScan
+		expanded := map[*Type]bool{}
+		for _, scan := range current {
+			if seen[scan.typ] {
+				continue
+			}
+			expanded[scan.typ] = true
+			for i, f := range scan.typ.Struct().Fields {
+				index := make([]int, len(scan.index)+1)
+				copy(index, scan.index)
+				index[len(scan.index)] = i
+				fi := FieldInfo{
+					Name:     f.Name(),
+					Type:     f.Type,
+					Index:    index,
+					Depth:    len(scan.index),
+					Offset:   scan.offset + f.offset,
+					Indirect: scan.indirect,
+					Exported: f.Exported(),
+					Embedded: f.Embedded(),
+					Tag:      f.Tag(),
+				}
+				if f.HasTag() {
+					fi.Tags = parseTag(fi.Tag)
+				}
+				fields = append(fields, fi)
+
+				if !f.Embedded() {
+					continue
+				}
+				ft, offset, indirect := f.Type, fi.Offset, scan.indirect
+				if ft.Kind() == KPtr && ft.Name() == "" {
+					ft, offset, indirect = ft.Ptr().Elem, 0, true
+				}
+				if ft.Kind() == KStruct {
+					next = append(next, fieldScan{ft, index, offset, indirect})
+				}
+			}
+		}
+		for typ := range expanded {
+			seen[typ] = true
+		}
+		current = next
+	}
+
+	// Shadowing, by name the least deep field wins if it is unique.
+	byName := make(map[string][]int)
+	for i, f := range fields {
+		byName[f.Name] = append(byName[f.Name], i)
+	}
+	for _, is := range byName {
+		// is is in breadth-first order, the least deep fields come first.
+		n := 1
+		for n < len(is) && fields[is[n]].Depth == fields[is[0]].Depth {
+			n++
+		}
+		for j, i := range is {
+			fields[i].Shadowed = j >= 1 || n > 1
+		}
+	}
+
+	sort.Sort(byIndex(fields))
+	return fields
+}
+
+type byIndex []FieldInfo
+
+func (s byIndex) Len() int      { return len(s) }
+func (s byIndex) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byIndex) Less(i, j int) bool {
+	a, b := s[i].Index, s[j].Index
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k] < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// parseTag returns the key:"value" pairs of tag, values split at commas.
+func parseTag(tag StructTag) map[string]TagValue {
+	tags := make(map[string]TagValue)
+	for tag != "" {
+		key, value, rest, ok := tag.next()
+		if !ok {
+			break
+		}
+		parts := strings.Split(value, ",")
+		tags[key] = TagValue{parts[0], parts[1:]}
+		tag = rest
+	}
+	return tags
+}
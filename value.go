@@ -10,6 +10,7 @@
 package surface
 
 import (
+	"math"
 	"unsafe"
 )
 
@@ -140,6 +141,15 @@ func (v Interface) InterfaceData() [2]uintptr {
 	return *(*[2]uintptr)(v.val)
 }
 
+// Elem returns the value that the interface v contains.
+// It returns the zero Value if v is a nil interface.
+func (v Interface) Elem() Value {
+	if v.TargetType == nil {
+		return Value{}
+	}
+	return Value{v.TargetType, sur{v.val, v.scalar, v.flag, unsafe.Pointer(v.TargetType)}}
+}
+
 func (v Ptr) Elem() Value {
 	val := v.val
 	if v.flag&flagIndir != 0 {
@@ -158,6 +168,187 @@ func (v Ptr) Elem() Value {
 	return Value{typ, sur{val, v.scalar, fl, unsafe.Pointer(tt.Elem)}}
 }
 
+// NumMethod returns the number of methods in the value's method set.
+// For an interface value it is the number of methods of the interface type.
+func (v Value) NumMethod() int {
+	if v.Type.Kind() == KInterface {
+		return v.Type.Surface().NumMethod()
+	}
+	return v.Type.NumMethod()
+}
+
+// Method returns a function value corresponding to v's i'th method.
+// The arguments to a Call on the returned function should not include
+// a receiver; the returned function will always use v as the receiver.
+// The method set of v follows the receiver rules of the language:
+// a value of type T only has the methods declared on T,
+// a value of type *T has the methods declared on both T and *T.
+// An unexported method is returned read-only and cannot be called.
+// Method panics if i is out of range or if v is a nil interface value.
+func (v Value) Method(i int) Func {
+	if v.flag == 0 {
+		panic(&ValueError{"surface.Value.Method", KInvalid})
+	}
+	if v.flag&flagMethod != 0 || i < 0 || i >= v.NumMethod() {
+		panic("surface: Method index out of range")
+	}
+	var (
+		mt       *FuncType
+		exported bool
+	)
+	if v.Type.Kind() == KInterface {
+		if v.IsNil() {
+			panic("surface: Method on nil interface value")
+		}
+		m := &v.Type.Surface().Methods[i]
+		mt, exported = m.Type, m.Exported()
+	} else {
+		m := &v.Type.Methods[i]
+		mt, exported = m.MethodType, m.Exported()
+	}
+	fl := v.flag & (flagRO | flagIndir)
+	if !exported {
+		fl |= flagRO
+	}
+	fl |= flag(KFunc) << flagKindShift
+	fl |= flag(i)<<flagMethodShift | flagMethod
+	// The receiver stays in typ+val, the method type is the Func type.
+	return Func{mt, sur{v.val, v.scalar, fl, v.typ}}
+}
+
+// MethodByName returns a function value corresponding to the exported
+// method of v with the given name. It returns the zero Func if no method
+// was found.
+func (v Value) MethodByName(name string) Func {
+	if v.flag == 0 {
+		panic(&ValueError{"surface.Value.MethodByName", KInvalid})
+	}
+	if v.flag&flagMethod != 0 {
+		panic("surface: MethodByName of method value")
+	}
+	if v.Type.Kind() == KInterface {
+		for i, m := range v.Type.Surface().Methods {
+			if m.Exported() && m.Name() == name {
+				return v.Method(i)
+			}
+		}
+		return Func{}
+	}
+	for i := 0; i < v.Type.NumMethod(); i++ {
+		m := &v.Type.Methods[i]
+		if m.Exported() && m.Name() == name {
+			return v.Method(i)
+		}
+	}
+	return Func{}
+}
+
+// IsMethod reports whether v is a method value bound to a receiver.
+func (v Func) IsMethod() bool {
+	return v.flag&flagMethod != 0
+}
+
+// MethodIndex returns the index of the method in the receiver's
+// method set. It panics if v is not a method value.
+func (v Func) MethodIndex() int {
+	if v.flag&flagMethod == 0 {
+		panic("surface: MethodIndex of non-method value")
+	}
+	return int(v.flag) >> flagMethodShift
+}
+
+// Unrestricted returns v with the read-only restriction removed.
+// Values obtained through unexported struct fields are read-only:
+// Interface panics on them and they cannot be set.
+// Unrestricted is the explicit escape hatch for code such as test fixtures
+// that needs to build or compare private state. The address restriction
+// is kept, only addressable values become settable.
+//
+// Unrestricted breaks the encapsulation of other packages. Use it sparingly.
+func Unrestricted(v Value) Value {
+	v.flag &^= flagRO
+	return v
+}
+
+// Pointer returns v's value as a uintptr.
+// For a slice it is the address of the first element of the backing array.
+// It panics if v's Kind is not KChan, KFunc, KMap, KPtr, KSlice, or KUnsafePointer,
+// or if v is a method value.
+func (v Value) Pointer() uintptr {
+	switch k := v.Kind(); k {
+	case KChan, KFunc, KMap, KPtr, KUnsafePointer:
+		if v.flag&flagMethod != 0 {
+			panic("surface: Pointer of method value")
+		}
+		p := v.val
+		if v.flag&flagIndir != 0 {
+			p = *(*unsafe.Pointer)(p)
+		}
+		return uintptr(p)
+	case KSlice:
+		return (*SliceHeader)(v.val).Data
+	default:
+		panic(&ValueError{"surface.Value.Pointer", k})
+	}
+}
+
+// UnsafeAddr returns a pointer to v's data.
+// It is for advanced clients that also import the "unsafe" package.
+// It panics if v is not addressable.
+func (v Value) UnsafeAddr() uintptr {
+	if v.flag == 0 {
+		panic(&ValueError{"surface.Value.UnsafeAddr", KInvalid})
+	}
+	if v.flag&flagAddr == 0 {
+		panic("surface: UnsafeAddr of unaddressable value")
+	}
+	return uintptr(v.val)
+}
+
+// IsZero reports whether v is the zero value for its type.
+// It panics if v is the zero Value.
+func (v Value) IsZero() bool {
+	switch k := v.Kind(); k {
+	case KBool:
+		return !v.Bool()
+	case KInt, KInt8, KInt16, KInt32, KInt64:
+		return v.Int64() == 0
+	case KUint, KUint8, KUint16, KUint32, KUint64, KUintptr:
+		return v.Uint64() == 0
+	case KFloat32, KFloat64:
+		f := v.Float64()
+		return f == 0 && !math.Signbit(f)
+	case KComplex64:
+		c := v.Complex64()
+		return real(c) == 0 && imag(c) == 0 && !math.Signbit(float64(real(c))) && !math.Signbit(float64(imag(c)))
+	case KComplex128:
+		c := v.Complex128()
+		return real(c) == 0 && imag(c) == 0 && !math.Signbit(real(c)) && !math.Signbit(imag(c))
+	case KString:
+		return v.String() == ""
+	case KChan, KFunc, KInterface, KMap, KPtr, KSlice, KUnsafePointer:
+		return v.IsNil()
+	case KArray:
+		a := v.Array()
+		for i := 0; i < a.Len(); i++ {
+			if !a.Index(i).IsZero() {
+				return false
+			}
+		}
+		return true
+	case KStruct:
+		s := v.Struct()
+		for i := range s.Type.Fields {
+			if !s.Field(i).IsZero() {
+				return false
+			}
+		}
+		return true
+	default:
+		panic(&ValueError{"surface.Value.IsZero", k})
+	}
+}
+
 // Indirect returns the value that v points to.
 // If v is a nil pointer, Indirect returns a zero Value.
 // If v is not a pointer, Indirect returns v.
@@ -192,6 +383,195 @@ func (v Array) Runes() []rune {
 	return *(*[]rune)(v.val)
 }
 
+// Slice returns v[i:j].
+// The returned Slice is a view sharing the backing array of v.
+func (v Slice) Slice(i, j int) Slice {
+	s := (*SliceHeader)(v.val)
+	if i < 0 || j < i || j > s.Cap {
+		panic("surface: slice index out of range")
+	}
+	return sliceView(v.Type, v.flag, v.DataPointer(), i, j, s.Cap)
+}
+
+// Slice3 is the 3-index form of the slice operation: it returns v[i:j:k].
+func (v Slice) Slice3(i, j, k int) Slice {
+	s := (*SliceHeader)(v.val)
+	if i < 0 || j < i || k < j || k > s.Cap {
+		panic("surface: slice index out of range")
+	}
+	return sliceView(v.Type, v.flag, v.DataPointer(), i, j, k)
+}
+
+// Slice returns v[i:j]. The array must be addressable,
+// the returned Slice is a view of the array memory.
+func (v Array) Slice(i, j int) Slice {
+	if v.flag&flagAddr == 0 {
+		panic("surface: slice of unaddressable array")
+	}
+	if i < 0 || j < i || j > v.Len() {
+		panic("surface: slice index out of range")
+	}
+	return sliceView(v.Type.Slice.Slice(), v.flag, v.val, i, j, v.Len())
+}
+
+// sliceView returns a Slice of type typ over base[i:j:k].
+func sliceView(typ *SliceType, fl flag, base unsafe.Pointer, i, j, k int) Slice {
+	// Declare slice so that gc can see the base pointer in it.
+	var x []unsafe.Pointer
+
+	// Reinterpret as *SliceHeader to edit.
+	s := (*SliceHeader)(unsafe.Pointer(&x))
+	s.Len = j - i
+	s.Cap = k - i
+	if k-i > 0 {
+		s.Data = uintptr(base) + uintptr(i)*typ.Elem.Size
+	} else {
+		// do not advance pointer, to avoid pointing beyond end of slice
+		s.Data = uintptr(base)
+	}
+
+	fl = fl&flagRO | flagIndir | flag(KSlice)<<flagKindShift
+	return Slice{typ, sur{unsafe.Pointer(&x), 0, fl, unsafe.Pointer(typ)}}
+}
+
+// DataPointer returns the address of the first element of the backing array.
+func (v Slice) DataPointer() unsafe.Pointer {
+	return *(*unsafe.Pointer)(v.val)
+}
+
+// span returns the byte range of the first n elements of v.
+func (v Slice) span(n int) (lo, hi uintptr) {
+	lo = uintptr(v.DataPointer())
+	return lo, lo + uintptr(n)*v.Type.Elem.Size
+}
+
+// Overlaps reports whether the elements of a and b, a[:len(a)] and b[:len(b)],
+// share at least one byte of memory. Writing to one is then visible through the other.
+func Overlaps(a, b Slice) bool {
+	alo, ahi := a.span(a.Len())
+	blo, bhi := b.span(b.Len())
+	return alo < ahi && blo < bhi && alo < bhi && blo < ahi
+}
+
+// SharesBacking reports whether the capacities of a and b, a[:cap(a)] and b[:cap(b)],
+// share memory of the same backing array.
+// Unlike Overlaps it also detects that an append to one may overwrite the other.
+func SharesBacking(a, b Slice) bool {
+	alo, ahi := a.span(a.Cap())
+	blo, bhi := b.span(b.Cap())
+	return alo < ahi && blo < bhi && alo < bhi && blo < ahi
+}
+
+// SetLen sets v's length to n.
+// It panics if CanSet() is false, or if n is negative or greater than the capacity.
+func (v Slice) SetLen(n int) {
+	v.mustBeAssignable()
+	s := (*SliceHeader)(v.val)
+	if n < 0 || n > s.Cap {
+		panic("surface: slice length out of range in SetLen")
+	}
+	s.Len = n
+}
+
+// SetCap sets v's capacity to n.
+// It panics if CanSet() is false, or if n is smaller than the length or
+// greater than the capacity of the slice.
+func (v Slice) SetCap(n int) {
+	v.mustBeAssignable()
+	s := (*SliceHeader)(v.val)
+	if n < s.Len || n > s.Cap {
+		panic("surface: slice capacity out of range in SetCap")
+	}
+	s.Cap = n
+}
+
+// Grow increases the slice's capacity, if necessary, to guarantee space for
+// another n elements. After Grow(n), at least n elements can be appended
+// to the slice without another allocation.
+// It panics if CanSet() is false or n is negative.
+func (v Slice) Grow(n int) {
+	v.mustBeAssignable()
+	if n < 0 {
+		panic("surface: negative length passed to Grow")
+	}
+	s := (*SliceHeader)(v.val)
+	if s.Len+n <= s.Cap {
+		return
+	}
+	g := v.extend(n).Slice(0, s.Len)
+	// A typed assignment, the new backing array is published with write barriers.
+	*(*[]unsafe.Pointer)(v.val) = *(*[]unsafe.Pointer)(g.val)
+}
+
+// extend returns v[:len(v)+n], reallocating the backing array when
+// the capacity is exceeded. The capacity is doubled while the slice is small,
+// then grows by a quarter, like the append of the language.
+func (v Slice) extend(n int) Slice {
+	i0 := v.Len()
+	i1 := i0 + n
+	if i1 < i0 {
+		panic("surface: slice overflow")
+	}
+	m := v.Cap()
+	if i1 <= m {
+		return v.Slice(0, i1)
+	}
+	if m == 0 {
+		m = n
+	} else {
+		for m < i1 {
+			if m < 1024 {
+				m += m
+			} else {
+				m += m / 4
+			}
+		}
+	}
+	t := MakeSlice(&v.Type.Type, i1, m)
+	typedslicecopy(t, v)
+	return t
+}
+
+// Append appends the values x to a slice s and returns the resulting slice.
+// As in Go, each x's value must be assignable to the slice's element type.
+func Append(s Slice, x ...Value) Slice {
+	s.mustBeExported()
+	n := s.Len()
+	s = s.extend(len(x))
+	for i, v := range x {
+		s.Index(n + i).Set(v)
+	}
+	return s
+}
+
+// AppendSlice appends a slice t to a slice s and returns the resulting slice.
+// The slices s and t must have the same element type.
+func AppendSlice(s, t Slice) Slice {
+	s.mustBeExported()
+	t.mustBeExported()
+	if s.Type.Elem != t.Type.Elem {
+		panic("surface: AppendSlice type mismatch")
+	}
+	n := s.Len()
+	m := t.Len()
+	s = s.extend(m)
+	typedslicecopy(s.Slice(n, n+m), t)
+	return s
+}
+
+// Copy copies the contents of src into dst until either
+// dst has been filled or src has been exhausted.
+// It returns the number of elements copied.
+// Dst and src must have the same element type.
+func Copy(dst, src Slice) int {
+	dst.mustBeExported()
+	src.mustBeExported()
+	if dst.Type.Elem != src.Type.Elem {
+		panic("surface: Copy type mismatch")
+	}
+	return typedslicecopy(dst, src)
+}
+
 func (v Map) Len() int {
 	return maplen(v.IWord())
 }
@@ -215,6 +595,11 @@ func (v Chan) Cap() int {
 	return int(chancap(v.IWord()))
 }
 
+// Closed reports whether the channel v has been closed.
+func (v Chan) Closed() bool {
+	return chanclosed(v.IWord())
+}
+
 func (v Array) Index(i int) Value {
 	tt := v.Type
 	if i < 0 || i > v.Len() {
@@ -347,6 +732,21 @@ func ValueOf(i interface{}) Value {
 	}
 }
 
+// Zero returns a Value representing the zero value for the specified type.
+// The result is different from the zero value of the Value struct,
+// which represents no value at all.
+// The returned value is neither addressable nor settable.
+func Zero(t *Type) Value {
+	if t == nil {
+		panic("surface: Zero(nil)")
+	}
+	fl := flag(t.Kind()) << flagKindShift
+	if t.Size <= ptrSize {
+		return Value{t, sur{nil, 0, fl, unsafe.Pointer(t)}}
+	}
+	return Value{t, sur{t.zero, 0, fl | flagIndir, unsafe.Pointer(t)}}
+}
+
 // Dummy annotation marking that the value x escapes,
 // for use in cases where the reflect code is so clever that
 // the compiler cannot follow.
@@ -0,0 +1,172 @@
+// Copyright 2014 The ZxxLang Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package surface
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A Node is a value visited by Walk.
+type Node struct {
+	Path   string // path from the root, e.g. Users[3].Profile.Name
+	Name   string // last element of Path: the field name, "[3]" or `["k"]`, empty for the root and for dereferences
+	Type   *Type  // type of Value
+	Value  Value
+	Parent *Node // nil for the root
+	Depth  int   // 0 for the root
+	Index  int   // field or element index in the parent, -1 if none
+	Key    Value // map key in the parent map, the zero Value if none
+
+	// Revisit is true for a pointer or map that was walked before
+	// through another path, or a cycle. Its children are not walked again.
+	Revisit bool
+}
+
+// A Visitor is called by Walk for every node.
+// Enter is called before the children of a node are walked, Leave after.
+// If Enter returns SkipChildren the children are not walked, Leave is still called.
+// Any other error stops the walk and is returned by Walk.
+type Visitor interface {
+	Enter(n *Node) error
+	Leave(n *Node) error
+}
+
+// SkipChildren is used as a return value from Visitor.Enter to indicate
+// that the children of the node are to be skipped.
+var SkipChildren = errors.New("surface: skip children")
+
+// VisitFunc is a Visitor calling the function on Enter.
+type VisitFunc func(n *Node) error
+
+func (f VisitFunc) Enter(n *Node) error {
+	return f(n)
+}
+
+func (f VisitFunc) Leave(n *Node) error {
+	return nil
+}
+
+// A Walker walks value graphs.
+type Walker struct {
+	// MaxDepth limits the depth of the walked nodes, the root has depth 0.
+	// Zero means no limit.
+	MaxDepth int
+}
+
+// Walk walks the value graph of v without depth limit.
+func Walk(v Value, vis Visitor) error {
+	return Walker{}.Walk(v, vis)
+}
+
+// Walk walks the value graph of v depth-first, calling vis for every node:
+// struct fields, unexported ones included, array and slice elements, map
+// entries in key order, the pointee of a pointer and the value in an interface.
+// A pointee or map is walked once, later encounters are reported as Revisit.
+// Values obtained through unexported fields are read-only, see Unrestricted.
+func (w Walker) Walk(v Value, vis Visitor) error {
+	if !v.IsValid() {
+		return nil
+	}
+	ws := &walker{w, vis, make(map[ptrKey]bool)}
+	return ws.walk(&Node{Type: v.Type, Value: v, Index: -1})
+}
+
+type walker struct {
+	Walker
+	vis     Visitor
+	visited map[ptrKey]bool
+}
+
+func (w *walker) walk(n *Node) error {
+	v := n.Value
+	var key ptrKey
+	if k := v.Kind(); (k == KPtr || k == KMap) && !v.IsNil() {
+		key = ptrKey{v.Pointer(), v.Type}
+		n.Revisit = w.visited[key]
+	}
+	err := w.vis.Enter(n)
+	if err == nil && !n.Revisit && (w.MaxDepth <= 0 || n.Depth < w.MaxDepth) {
+		// Only a pointer or map whose children are walked is visited,
+		// a shallower path reaching it later walks them.
+		if key.typ != nil {
+			w.visited[key] = true
+		}
+		err = w.children(n)
+	}
+	if err == SkipChildren {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = w.vis.Leave(n); err == SkipChildren {
+		err = nil
+	}
+	return err
+}
+
+func (w *walker) child(parent *Node, name string, path string, v Value, index int, key Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+	return w.walk(&Node{
+		Path:   path,
+		Name:   name,
+		Type:   v.Type,
+		Value:  v,
+		Parent: parent,
+		Depth:  parent.Depth + 1,
+		Index:  index,
+		Key:    key,
+	})
+}
+
+func (w *walker) children(n *Node) (err error) {
+	v := n.Value
+	switch v.Kind() {
+	case KPtr:
+		if !v.IsNil() {
+			return w.child(n, "", n.Path, v.Ptr().Elem(), -1, Value{})
+		}
+
+	case KInterface:
+		return w.child(n, "", n.Path, v.Surface().Elem(), -1, Value{})
+
+	case KStruct:
+		s := v.Struct()
+		for i, f := range s.Type.Fields {
+			name := f.Name()
+			if err = w.child(n, name, joinField(n.Path, name), s.Field(i), i, Value{}); err != nil {
+				return
+			}
+		}
+
+	case KArray:
+		a := v.Array()
+		for i := 0; i < a.Len(); i++ {
+			if err = w.child(n, "["+strconv.Itoa(i)+"]", joinIndex(n.Path, i), a.Index(i), i, Value{}); err != nil {
+				return
+			}
+		}
+
+	case KSlice:
+		s := v.Slice()
+		for i := 0; i < s.Len(); i++ {
+			if err = w.child(n, "["+strconv.Itoa(i)+"]", joinIndex(n.Path, i), s.Index(i), i, Value{}); err != nil {
+				return
+			}
+		}
+
+	case KMap:
+		m := v.Map()
+		for _, key := range sortKeys(m.Keys()) {
+			if err = w.child(n, "["+formatKey(key)+"]", joinKey(n.Path, key), m.Index(key), -1, key); err != nil {
+				return
+			}
+		}
+	}
+	return
+}